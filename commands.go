@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// runValidateConfig implements the validate-config subcommand. It parses the
+// policy file on top of the flag defaults, reports the first problem found and
+// returns the process exit code.
+func runValidateConfig(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	path := fs.String("config", "", "Path to the YAML policy file to validate")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *path == "" {
+		fmt.Fprintln(stderr, "validate-config: --config is required")
+		return 2
+	}
+
+	defaults, err := policyFromFlags()
+	if err != nil {
+		fmt.Fprintf(stderr, "validate-config: invalid defaults: %v\n", err)
+		return 1
+	}
+	if _, err := policy.Load(*path, defaults); err != nil {
+		fmt.Fprintf(stderr, "validate-config: %s: %v\n", *path, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s is valid\n", *path)
+	return 0
+}
+
+// runEval implements the eval subcommand. It evaluates a pod manifest against
+// the policy file, or the flag defaults, without a cluster and prints the
+// verdict. The exit code is 0 when the pod is allowed, 1 when it is denied
+// and 2 when the input cannot be used.
+func runEval(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("eval", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "", "Path to the YAML policy file. Empty evaluates against the flag defaults")
+	podPath := fs.String("pod", "", "Path to the pod manifest, YAML or JSON")
+	namespace := fs.String("namespace", "", "Namespace the pod is created in. Defaults to the manifest's namespace, then default")
+	namespaceLabels := fs.String("namespace-labels", "", "Comma-separated key=value labels of the namespace, e.g. gpu-policy/allowed=true")
+	namespaceAnnotations := fs.String("namespace-annotations", "", "Comma-separated key=value annotations of the namespace")
+	allowLabel := fs.String("namespace-allow-label", "gpu-policy/allowed=true", "Namespace label (key=value) that opts a namespace in to GPUs beyond the per-pod limit")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *podPath == "" {
+		fmt.Fprintln(stderr, "eval: --pod is required")
+		return 2
+	}
+
+	defaults, err := policyFromFlags()
+	if err != nil {
+		fmt.Fprintf(stderr, "eval: invalid defaults: %v\n", err)
+		return 2
+	}
+	p := &defaults
+	if *configPath != "" {
+		if p, err = policy.Load(*configPath, defaults); err != nil {
+			fmt.Fprintf(stderr, "eval: %s: %v\n", *configPath, err)
+			return 2
+		}
+	}
+
+	data, err := os.ReadFile(*podPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "eval: %v\n", err)
+		return 2
+	}
+	var pod corev1.Pod
+	if err := yaml.Unmarshal(data, &pod); err != nil {
+		fmt.Fprintf(stderr, "eval: %s: %v\n", *podPath, err)
+		return 2
+	}
+	if *namespace == "" {
+		*namespace = pod.Namespace
+	}
+	if *namespace == "" {
+		*namespace = "default"
+	}
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: *namespace}}
+	if *namespaceLabels != "" {
+		if ns.Labels, err = parseKeyValues(*namespaceLabels); err != nil {
+			fmt.Fprintf(stderr, "eval: invalid --namespace-labels: %v\n", err)
+			return 2
+		}
+	}
+	if *namespaceAnnotations != "" {
+		if ns.Annotations, err = parseKeyValues(*namespaceAnnotations); err != nil {
+			fmt.Fprintf(stderr, "eval: invalid --namespace-annotations: %v\n", err)
+			return 2
+		}
+	}
+	key, value, ok := strings.Cut(*allowLabel, "=")
+	if !ok || key == "" {
+		fmt.Fprintf(stderr, "eval: invalid --namespace-allow-label %q, expected key=value\n", *allowLabel)
+		return 2
+	}
+
+	evaluator := &policy.Evaluator{
+		Cluster:         policy.StaticCluster{Namespace: ns},
+		AllowLabelKey:   key,
+		AllowLabelValue: value,
+	}
+	decision := evaluator.Evaluate(context.Background(), p, &pod, *namespace)
+	for _, warning := range decision.Warnings {
+		fmt.Fprintf(stdout, "warning: %s\n", warning)
+	}
+	if !decision.Allowed {
+		fmt.Fprintf(stdout, "denied (%s): %s\n", decision.Reason, decision.Message)
+		return 1
+	}
+	fmt.Fprintf(stdout, "allowed (%s)\n", decision.Reason)
+	return 0
+}
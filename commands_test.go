@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunValidateConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		wantCode int
+	}{
+		{
+			name:     "valid",
+			config:   "gpuPrefixes: [nvidia.com, amd.com]\nmaxGPUsPerPod: 2\nexemptServiceAccounts: [kube-system/device-plugin]\n",
+			wantCode: 0,
+		},
+		{
+			name:     "empty prefix",
+			config:   "gpuPrefixes: [\"\"]\n",
+			wantCode: 1,
+		},
+		{
+			name:     "negative namespace quota",
+			config:   "namespaces:\n  ml:\n    maxGPUs: -1\n",
+			wantCode: 1,
+		},
+		{
+			name:     "malformed service account",
+			config:   "exemptServiceAccounts: [device-plugin]\n",
+			wantCode: 1,
+		},
+		{
+			name:     "invalid pod selector",
+			config:   "podSelector: \"team in (ml\"\n",
+			wantCode: 1,
+		},
+		{
+			name:     "unknown field",
+			config:   "maxGPUsPerNode: 2\n",
+			wantCode: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.yaml")
+			if err := os.WriteFile(path, []byte(tt.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			var stdout, stderr bytes.Buffer
+			if code := runValidateConfig([]string{"--config", path}, &stdout, &stderr); code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d (stderr: %s)", code, tt.wantCode, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunEval(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "policy.yaml")
+	if err := os.WriteFile(configPath, []byte("maxGPUsPerPod: 2\nmaxGPUsPerNamespace: -1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writePod := func(gpus int) string {
+		path := filepath.Join(dir, fmt.Sprintf("pod-%d.yaml", gpus))
+		manifest := fmt.Sprintf(`apiVersion: v1
+kind: Pod
+metadata:
+  name: train
+  namespace: ml
+spec:
+  containers:
+  - name: app
+    image: busybox
+    resources:
+      limits:
+        nvidia.com/gpu: %d
+`, gpus)
+		if err := os.WriteFile(path, []byte(manifest), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOutput string
+	}{
+		{name: "allowed", args: []string{"--pod", writePod(2)}, wantCode: 0, wantOutput: "allowed (within_limit)\n"},
+		{name: "denied", args: []string{"--pod", writePod(4)}, wantCode: 1, wantOutput: "denied (max_gpus_exceeded): pod requests 4 GPUs, exceeding the limit of 2 per pod in namespace ml\n"},
+		{name: "opted-in namespace", args: []string{"--pod", writePod(4), "--namespace-labels", "gpu-policy/allowed=true"}, wantCode: 0, wantOutput: "allowed (namespace_allowed)\n"},
+		{name: "missing pod", args: []string{"--pod", filepath.Join(dir, "missing.yaml")}, wantCode: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := runEval(append([]string{"--config", configPath}, tt.args...), &stdout, &stderr); code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d (stderr: %s)", code, tt.wantCode, stderr.String())
+			}
+			if tt.wantOutput != "" && stdout.String() != tt.wantOutput {
+				t.Errorf("output = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// logComponents maps the components accepted by --log-levels to the source
+// files, as klog --vmodule patterns, whose log statements they cover.
+var logComponents = map[string][]string{
+	"admission": {"server", "mutate", "rego", "delegate", "resize", "template", "workload", "audit", "events", "requestid"},
+	"policy":    {"evaluate", "priority", "policy", "quota", "capacity", "limitrange", "schedule", "selftest"},
+	"cache":     {"namespace", "informers", "configmap", "reload", "policycache"},
+	"api":       {"retry", "cluster", "stateless"},
+	"budget":    {"userbudget", "redis"},
+	"serving":   {"certs", "health", "debug"},
+}
+
+// vmoduleForLogLevels translates a comma-separated list of component=level
+// pairs into klog --vmodule patterns.
+func vmoduleForLogLevels(s string) (string, error) {
+	var patterns []string
+	for _, pair := range strings.Split(s, ",") {
+		component, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return "", fmt.Errorf("invalid pair %q, expected component=level", pair)
+		}
+		files, ok := logComponents[component]
+		if !ok {
+			components := make([]string, 0, len(logComponents))
+			for name := range logComponents {
+				components = append(components, name)
+			}
+			slices.Sort(components)
+			return "", fmt.Errorf("unknown log component %q, must be one of %s", component, strings.Join(components, ", "))
+		}
+		level, err := strconv.ParseUint(value, 10, 31)
+		if err != nil {
+			return "", fmt.Errorf("invalid level %q for log component %s", value, component)
+		}
+		for _, file := range files {
+			patterns = append(patterns, fmt.Sprintf("%s=%d", file, level))
+		}
+	}
+	return strings.Join(patterns, ","), nil
+}
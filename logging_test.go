@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVmoduleForLogLevels(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "cache=5", want: "namespace=5,informers=5,configmap=5,reload=5,policycache=5"},
+		{in: "api=4,budget=0", want: "retry=4,cluster=4,stateless=4,userbudget=0,redis=0"},
+		{in: "cache", wantErr: true},
+		{in: "informers=4", wantErr: true},
+		{in: "cache=-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := vmoduleForLogLevels(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLogComponentsCoverLoggingFiles fails when a file that logs is not
+// reachable through --log-levels.
+func TestLogComponentsCoverLoggingFiles(t *testing.T) {
+	covered := make(map[string]bool)
+	for _, files := range logComponents {
+		for _, file := range files {
+			covered[file] = true
+		}
+	}
+	for _, dir := range []string{"server", "policy"} {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range paths {
+			if strings.HasSuffix(path, "_test.go") {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file := strings.TrimSuffix(filepath.Base(path), ".go"); strings.Contains(string(data), "klog.") && !covered[file] {
+				t.Errorf("%s logs but is not in any log component", path)
+			}
+		}
+	}
+}
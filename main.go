@@ -1,156 +1,434 @@
 package main
 
 import (
-	"crypto/tls"
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
-	"io"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/klog/v2"
-	"net/http"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
+	// Schedules need the zone database, which the alpine image lacks.
+	_ "time/tzdata"
 
-	"k8s.io/api/admission/v1"
+	"github.com/go-logr/logr"
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	"github.com/mayooot/gpu-policy-webhook/server"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
 )
 
 var (
-	port        = flag.Int("port", 8443, "Webhook server port")
-	certFile    = flag.String("tls-cert", "/etc/webhook/certs/tls.crt", "TLS certificate file")
-	keyFile     = flag.String("tls-key", "/etc/webhook/certs/tls.key", "TLS key file")
-	gpuPrefixes = flag.String("gpu-prefixes", "nvidia.com", "Comma-separated GPU resource prefixes (e.g., nvidia.com,amd.com)")
-	kubeconfig  = flag.String("kubeconfig", "", "Path to a kubeconfig. If not specified will use default path, then in-cluster config")
-)
+	port                     = flag.Int("port", 8443, "Webhook server port")
+	pathPrefix               = flag.String("path-prefix", "", "Prefix for the admission paths, e.g. /gpu-webhook for /gpu-webhook/validate. Metrics and health endpoints are not prefixed")
+	bindAddress              = flag.String("bind-address", "0.0.0.0", "IP address the webhook server listens on, e.g. 127.0.0.1 behind a sidecar proxy")
+	certFile                 = flag.String("tls-cert", "/etc/webhook/certs/tls.crt", "TLS certificate file")
+	keyFile                  = flag.String("tls-key", "/etc/webhook/certs/tls.key", "TLS key file")
+	clientCAFile             = flag.String("client-ca-file", "", "If set, require clients (the API server) to present a certificate signed by a CA in this file")
+	clientCertNamesFlag      = flag.String("client-cert-names", "", "Comma-separated common or DNS names accepted in client certificates, requires --client-ca-file (empty accepts any name)")
+	gpuPrefixes              = flag.String("gpu-prefixes", "nvidia.com", "Comma-separated GPU resource prefixes (e.g., nvidia.com,amd.com)")
+	gpuSuffixes              = flag.String("gpu-suffixes", "", "Comma-separated GPU resource suffixes (e.g., /gpu for example.com/accelerator-gpu), matched in addition to --gpu-prefixes")
+	deprecatedGPUPrefixes    = flag.String("deprecated-gpu-prefixes", "", "Comma-separated resource prefixes (e.g., amd.com) whose requests are denied on pod creation. Existing pods are not affected")
+	gpuMatchMode             = flag.String("gpu-match-mode", policy.MatchModePrefix, "How --gpu-prefixes are matched against resource names: prefix, glob or regex")
+	podSelector              = flag.String("pod-selector", "", "Label selector (e.g. \"team in (ml, research)\") limiting the policy to matching pods. Empty selects every pod")
+	denyUnlistedAccelerators = flag.Bool("deny-unlisted-accelerators", false, "Deny pods requesting extended resources with gpu, tpu or fpga in their name that do not match --gpu-prefixes")
+	requireGPULimits         = flag.Bool("require-gpu-limits", false, "Deny containers that request GPUs but set no resource limits at all")
+	minGPUContainerCPU       = flag.String("min-gpu-container-cpu", "", "Least CPU, e.g. 2, that every container requesting a GPU must request. Empty disables the floor")
+	minGPUContainerMemory    = flag.String("min-gpu-container-memory", "", "Least memory, e.g. 8Gi, that every container requesting a GPU must request. Empty disables the floor")
+	strictZeroGPURequests    = flag.Bool("strict-zero-gpu-requests", false, "Treat GPU resources requested with a quantity of 0 as GPU requests. By default pods requesting nvidia.com/gpu: 0 are treated as requesting no GPU")
+	applyDefaults            = flag.Bool("apply-defaults", false, "Evaluate pods after applying the API server's resource defaulting, which requests every resource with a limit but no request at its limit")
+	metricsPort              = flag.Int("metrics-port", 8080, "Plaintext port serving Prometheus metrics")
+	enableDebug              = flag.Bool("enable-debug", false, "Serve /debug endpoints on the metrics port")
+	recentDecisionsSize      = flag.Int("recent-decisions", 100, "Number of recent denials and warnings served on /debug/recent when --enable-debug is set")
+	kubeconfig               = flag.String("kubeconfig", "", "Path to a kubeconfig. If not specified will use default path, then in-cluster config")
+	requireClientset         = flag.Bool("require-clientset", false, "Exit when no kubernetes clientset can be built, instead of running without one when no enabled feature needs it")
+	apiQPS                   = flag.Float64("api-qps", 20, "Maximum queries per second admission requests make to the API server, calls beyond it are handled according to --fail-open")
+	apiBurst                 = flag.Int("api-burst", 40, "Maximum burst of queries admission requests make to the API server")
+	apiTimeout               = flag.Duration("api-timeout", server.DefaultAPITimeout, "Deadline for the API server calls made while evaluating a single admission request. Keep it below the webhook timeoutSeconds")
+	readHeaderTimeout        = flag.Duration("read-header-timeout", server.DefaultReadHeaderTimeout, "Time allowed to read the request headers of a webhook connection. Zero disables the timeout")
+	readTimeout              = flag.Duration("read-timeout", server.DefaultReadTimeout, "Time allowed to read a whole webhook request, including the body. Zero disables the timeout")
+	writeTimeout             = flag.Duration("write-timeout", server.DefaultWriteTimeout, "Time allowed to handle a webhook request and write the response. Zero disables the timeout")
+	idleTimeout              = flag.Duration("idle-timeout", server.DefaultIdleTimeout, "Time an idle keep-alive webhook connection is kept open. Zero disables the timeout")
+	shutdownGracePeriod      = flag.Duration("shutdown-grace-period", 30*time.Second, "Time allowed for in-flight requests to complete on shutdown")
+	logFormat                = flag.String("log-format", "text", "Log format: text or json")
+	logLevels                = flag.String("log-levels", "", "Comma-separated component=level pairs overriding -v for admission, policy, cache, api, budget and serving logs, e.g. admission=0,cache=5")
+	auditLogPath             = flag.String("audit-log-path", "", "File that denied admissions are appended to as JSON lines. Defaults to stdout")
+	emitEvents               = flag.Bool("emit-events", false, "Emit a Warning event on the owning controller or namespace for each denied pod")
+	eventThrottle            = flag.Duration("event-throttle", time.Minute, "Minimum interval between identical denial events")
+	configFile               = flag.String("config", "", "Path to a YAML policy file. Reloaded automatically when it changes")
+	policyConfigMap          = flag.String("policy-configmap", "", "ConfigMap (namespace/name) holding the policy under the policy.yaml key, watched through the API server instead of --config")
+	templatePaths            = flag.String("template-paths", "", "Semicolon-separated group/version/resource=jsonpath entries locating the pod template embedded in custom resources validated on /validate-templates, e.g. example.com/v1/trainingruns=.spec.worker.template")
+	regoPolicy               = flag.String("rego-policy", "", "Rego file deciding admission requests through its data.gpupolicy allow and deny rules instead of the native policy. Requires a build with the rego tag")
+	delegateURL              = flag.String("delegate-url", "", "URL of a service that receives the AdmissionReview of every GPU pod the policy allows and can still deny it. Failures are handled according to --fail-open")
+	delegateTimeout          = flag.Duration("delegate-timeout", server.DefaultDelegateTimeout, "Timeout for calls to --delegate-url")
+	updateGrace              = flag.Bool("update-grace", false, "Allow updates that do not raise the GPU requests of a pod or workload template without evaluating the policy")
+	maxGPUsPerUser           = flag.Int64("max-gpus-per-user", 0, "Maximum GPUs granted to pods created by a single user within --user-budget-window, tracked in memory by each replica. Zero disables the budget")
+	userBudgetWindow         = flag.Duration("user-budget-window", server.DefaultUserBudgetWindow, "Sliding window of --max-gpus-per-user")
+	redisAddr                = flag.String("redis-addr", "", "Redis address (host:port) sharing the --max-gpus-per-user budgets between replicas. Redis errors are handled according to --fail-open")
+	redisPassword            = flag.String("redis-password", os.Getenv("REDIS_PASSWORD"), "Redis password, defaults to the REDIS_PASSWORD environment variable so that it can be read from a Secret")
+	redisDB                  = flag.Int("redis-db", 0, "Redis database holding the user budgets")
+	redisTLS                 = flag.Bool("redis-tls", false, "Connect to Redis over TLS")
+	redisCAFile              = flag.String("redis-ca-file", "", "CA file verifying the Redis server certificate, implies --redis-tls (default: system roots)")
 
-type WebhookServer struct {
-	scheme  *runtime.Scheme
-	decoder *serializer.CodecFactory
+	namespaceAllowLabel         = flag.String("namespace-allow-label", "gpu-policy/allowed=true", "Namespace label (key=value) that opts a namespace in to GPU usage")
+	namespaceCacheTTL           = flag.Duration("namespace-cache-ttl", 30*time.Second, "How long namespace lookups are cached when --use-informers=false")
+	namespaceCacheSize          = flag.Int("namespace-cache-size", server.DefaultNamespaceCacheSize, "Maximum number of namespaces cached, as Namespace objects when --use-informers=false and as resolved policies otherwise. Least recently used ones are evicted first")
+	useInformers                = flag.Bool("use-informers", true, "Serve namespace (and, with a namespace quota or --check-resource-quota, pod) lookups from shared informer caches instead of direct API calls")
+	failOpen                    = flag.Bool("fail-open", false, "Allow GPU pods when the namespace cannot be fetched (default is to deny)")
+	maxGPUsPerPod               = flag.Int64("max-gpus-per-pod", -1, "Maximum number of GPUs a single pod may request. Negative denies any GPU request")
+	maxGPUsPerContainer         = flag.Int64("max-gpus-per-container", -1, "Maximum number of GPUs a single container may request. Negative disables the limit")
+	maxGPUsPerOwnerKind         = flag.String("max-gpus-per-owner-kind", "", "Comma-separated kind=limit overrides of --max-gpus-per-pod by controller kind, e.g. Standalone=0,Job=8 (Deployment pods are owned by ReplicaSets)")
+	maxGPUsAnnotation           = flag.String("max-gpus-annotation", policy.DefaultMaxGPUsAnnotation, "Pod annotation overriding the per-pod GPU limit, up to --max-gpus-annotation-ceiling. It only raises the limit in namespaces with allowMaxGPUsAnnotation")
+	maxGPUsAnnotationCeiling    = flag.Int64("max-gpus-annotation-ceiling", -1, "Highest per-pod GPU limit the --max-gpus-annotation may grant. Negative ignores the annotation")
+	maxMIGDevicesPerPod         = flag.Int64("max-mig-devices-per-pod", -1, "Maximum number of nvidia.com/mig-* slices a single pod may request. Negative counts MIG slices as full GPUs")
+	timeSlicedResources         = flag.String("time-sliced-resources", "", "Comma-separated resource names (e.g. nvidia.com/gpu.shared) advertised as time-sliced GPU replicas")
+	timeSlicedNamespaces        = flag.String("time-sliced-namespaces", "", "Comma-separated namespaces whose GPUs are all time-sliced replicas, limited by --max-time-sliced-replicas-per-pod")
+	maxTimeSlicedReplicasPerPod = flag.Int64("max-time-sliced-replicas-per-pod", -1, "Maximum number of time-sliced GPU replicas a single pod may request. Negative counts replicas as full GPUs")
+	gpuMemoryResources          = flag.String("gpu-memory-resources", "nvidia.com/gpu-memory", "Comma-separated resources that express GPU memory rather than a device count")
+	maxGPUMemory                = flag.String("max-gpu-memory", "", "Maximum GPU memory a single pod may request, e.g. 48Gi. Empty disables the limit")
+	gpuMemoryUnit               = flag.String("gpu-memory-unit", "", "Size of one unit of a whole decimal GPU memory quantity, e.g. 1Mi for plugins that advertise MiB. Quantities with a binary suffix such as 8Gi are taken as bytes")
+	fractionalGPUResources      = flag.String("fractional-gpu-resources", "", "Comma-separated resource=denominator pairs for resources expressing a share of a GPU, e.g. tencent.com/vcuda-core=100 where 100 is one GPU")
+	maxFractionalGPUs           = flag.String("max-fractional-gpus", "", "Maximum whole GPUs, e.g. 1.5, a single pod may request through --fractional-gpu-resources. Empty disables the limit")
+	maxGPUsPerNamespace         = flag.Int64("max-gpus-per-namespace", -1, "Maximum number of GPUs requested by running pods in a namespace. Negative disables the quota")
+	skipNamespaces              = flag.String("skip-namespaces", "kube-system,kube-public,kube-node-lease", "Comma-separated namespaces whose pods are always allowed, such as cluster infrastructure running the device plugin")
+	exemptServiceAccounts       = flag.String("exempt-service-accounts", "", "Comma-separated service accounts (namespace/name) whose pods bypass the GPU policy")
+	gpuNodeSelector             = flag.String("gpu-node-selector", "", "Comma-separated key=value node selector injected into GPU pods by /mutate")
+	annotateDecisions           = flag.Bool("annotate-decisions", false, "Make /mutate annotate allowed GPU pods with gpu-policy/evaluated, recording the decision reason, limit and policy config hash")
+	allowedGPUProducts          = flag.String("allowed-gpu-products", "", "Comma-separated GPU products pods may select. Empty allows every product")
+	gpuProductLabel             = flag.String("gpu-product-label", policy.DefaultGPUProductLabel, "Node label used to select a GPU product")
+	gpuNodeAffinity             = flag.String("gpu-node-affinity", "", "Semicolon-separated resource=requirement entries, e.g. amd.com/gpu=gpu-pool in (amd), denying pods that request the resource without requiring matching nodes through nodeSelector or node affinity")
+	allowUnspecifiedProduct     = flag.Bool("allow-unspecified-gpu-product", true, "Allow GPU pods that do not select a product when --allowed-gpu-products is set")
+	onError                     = flag.String("on-error", server.OnErrorDeny, "Verdict for requests whose AdmissionReview or pod cannot be decoded: allow or deny")
+	checkResourceQuota          = flag.Bool("check-resource-quota", false, "Deny GPU pods that would exceed a ResourceQuota in their namespace with a descriptive message")
+	applyLimitRangeDefaults     = flag.Bool("apply-limit-range-defaults", false, "Apply the container defaults of the namespace's LimitRanges to pods before evaluating them. Adds an API call per pod")
+	checkNodeCapacity           = flag.Bool("check-node-capacity", false, "Deny GPU pods requesting more GPUs than the largest node can allocate, since they can never be scheduled. Needs list (and watch with --use-informers) on nodes")
+	exemptPriorityClasses       = flag.String("exempt-priority-classes", "", "Comma-separated priority class names whose pods bypass the GPU policy")
+	minExemptPriority           = flag.String("min-exempt-priority", "", "Pods with at least this numeric priority bypass the GPU policy. Empty disables the threshold")
+	maxRequestBytes             = flag.Int64("max-request-bytes", server.DefaultMaxRequestBytes, "Maximum size of an admission request body")
+	maxConcurrentRequests       = flag.Int("max-concurrent-requests", 0, "Maximum number of admission requests handled at once, others get 429 with Retry-After. Zero disables the limit")
+	allowedGPUImageRegistries   = flag.String("allowed-gpu-image-registries", "", "Comma-separated registries (e.g. docker.io,registry.example.com) GPU containers may pull images from. Empty allows every registry")
+	allowedRuntimeClasses       = flag.String("allowed-runtime-classes", "", "Comma-separated runtime class names (e.g. nvidia) GPU pods must set in spec.runtimeClassName. Empty does not require one")
+	requiredGPULabel            = flag.String("required-gpu-label", "", "Label GPU pods must carry, e.g. finance/cost-center. Empty does not require one")
+	requiredGPULabelPattern     = flag.String("required-gpu-label-pattern", "", "Regular expression the whole value of --required-gpu-label must match. Empty accepts any non-empty value")
+	gpuSchedule                 = flag.String("gpu-schedule", "", "Comma-separated windows (e.g. \"Mon-Fri 08:00-18:00\") during which new GPU pods are admitted. Empty admits them at any time")
+	gpuScheduleTimeZone         = flag.String("gpu-schedule-timezone", "", "IANA time zone (e.g. Europe/Berlin) of the --gpu-schedule windows. Required with --gpu-schedule")
+	denyMessageTemplate         = flag.String("deny-message-template", "", "Go text/template for denial messages with {{.Namespace}}, {{.PodName}}, {{.Resource}}, {{.Limit}}, {{.Reason}} and {{.Message}}")
+	mode                        = flag.String("mode", policy.ModeEnforce, "Enforcement mode: enforce denies violating pods, warn admits them with a warning")
+)
 
-	gpuPrefixes []string
-	kubeconfig  string
-	clientset   *kubernetes.Clientset
-}
+// Build information, set at build time with
+// -ldflags "-X main.version=v1.2.3 -X main.gitCommit=... -X main.buildDate=...".
+var (
+	version   = "dev"
+	gitCommit = "unknown"
+	buildDate = "unknown"
+)
 
-func NewWebhookServer() *WebhookServer {
-	scheme := runtime.NewScheme()
-	_ = corev1.AddToScheme(scheme)
-	_ = v1.AddToScheme(scheme)
-	codecFactory := serializer.NewCodecFactory(scheme)
-	return &WebhookServer{
-		scheme:  scheme,
-		decoder: &codecFactory,
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate-config" {
+		os.Exit(runValidateConfig(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "eval" {
+		os.Exit(runEval(os.Args[2:], os.Stdout, os.Stderr))
 	}
-}
 
-func main() {
+	klog.InitFlags(nil)
 	flag.Parse()
 
-	server := NewWebhookServer()
-	server.gpuPrefixes = strings.Split(*gpuPrefixes, ",")
-	server.kubeconfig = *kubeconfig
-	server.initClientsetOrDie()
+	if *logLevels != "" {
+		vmodule, err := vmoduleForLogLevels(*logLevels)
+		if err != nil {
+			klog.Fatalf("Invalid --log-levels: %v", err)
+		}
+		// Explicit --vmodule patterns come first and so take precedence.
+		if explicit := flag.Lookup("vmodule").Value.String(); explicit != "" {
+			vmodule = explicit + "," + vmodule
+		}
+		if err := flag.Set("vmodule", vmodule); err != nil {
+			klog.Fatalf("Invalid --log-levels: %v", err)
+		}
+	}
+
+	switch *logFormat {
+	case "text":
+	case "json":
+		// klog filters by -v and --vmodule before handing messages to slog,
+		// so the handler must not drop verbose levels itself.
+		handler := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.Level(-127)})
+		klog.SetLoggerWithOptions(logr.FromSlogHandler(handler))
+	default:
+		klog.Fatalf("Invalid --log-format %q, must be text or json", *logFormat)
+	}
+
+	klog.Infof("gpu-policy-webhook %s (commit %s, built %s)", version, gitCommit, buildDate)
 
-	http.HandleFunc("/validate", server.validatePod)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
-	// Set up TLS
-	tlsConfig := &tls.Config{
-		MinVersion: tls.VersionTLS12,
+	defaults, err := policyFromFlags()
+	if err != nil {
+		klog.Fatalf("Invalid policy flags: %v", err)
 	}
-	srv := &http.Server{
-		Addr:      fmt.Sprintf(":%d", *port),
-		TLSConfig: tlsConfig,
+	var clientCertNames []string
+	if *clientCertNamesFlag != "" {
+		clientCertNames = strings.Split(*clientCertNamesFlag, ",")
 	}
-
-	klog.Infof("Starting webhook server on port %d with GPU prefixes: %v", *port, *gpuPrefixes)
-	if err := srv.ListenAndServeTLS(*certFile, *keyFile); err != nil {
-		klog.Fatalf("Failed to start server: %v", err)
+	templatePathsByResource := make(map[string]string)
+	if *templatePaths != "" {
+		for _, entry := range strings.Split(*templatePaths, ";") {
+			resource, path, ok := strings.Cut(entry, "=")
+			if !ok {
+				klog.Fatalf("Invalid --template-paths entry %q, expected group/version/resource=jsonpath", entry)
+			}
+			templatePathsByResource[resource] = path
+		}
 	}
+	webhook, err := server.New(ctx, server.Config{
+		BindAddress:             *bindAddress,
+		Port:                    *port,
+		PathPrefix:              *pathPrefix,
+		MetricsPort:             *metricsPort,
+		CertFile:                *certFile,
+		KeyFile:                 *keyFile,
+		ClientCAFile:            *clientCAFile,
+		ClientCertNames:         clientCertNames,
+		Kubeconfig:              *kubeconfig,
+		APIQPS:                  float32(*apiQPS),
+		APIBurst:                *apiBurst,
+		APITimeout:              *apiTimeout,
+		ReadHeaderTimeout:       *readHeaderTimeout,
+		ReadTimeout:             *readTimeout,
+		WriteTimeout:            *writeTimeout,
+		IdleTimeout:             *idleTimeout,
+		ShutdownGracePeriod:     *shutdownGracePeriod,
+		AuditLogPath:            *auditLogPath,
+		EmitEvents:              *emitEvents,
+		EventThrottle:           *eventThrottle,
+		EnableDebug:             *enableDebug,
+		RecentDecisions:         *recentDecisionsSize,
+		NamespaceAllowLabel:     *namespaceAllowLabel,
+		NamespaceCacheTTL:       *namespaceCacheTTL,
+		NamespaceCacheSize:      *namespaceCacheSize,
+		UseInformers:            *useInformers,
+		FailOpen:                *failOpen,
+		OnError:                 *onError,
+		CheckResourceQuota:      *checkResourceQuota,
+		ApplyLimitRangeDefaults: *applyLimitRangeDefaults,
+		CheckNodeCapacity:       *checkNodeCapacity,
+		MaxRequestBytes:         *maxRequestBytes,
+		MaxConcurrentRequests:   *maxConcurrentRequests,
+		AnnotateDecisions:       *annotateDecisions,
+		PolicyFile:              *configFile,
+		PolicyConfigMap:         *policyConfigMap,
+		TemplatePaths:           templatePathsByResource,
+		RegoPolicy:              *regoPolicy,
+		DelegateURL:             *delegateURL,
+		DelegateTimeout:         *delegateTimeout,
+		UpdateGrace:             *updateGrace,
+		MaxGPUsPerUser:          *maxGPUsPerUser,
+		UserBudgetWindow:        *userBudgetWindow,
+		RedisAddr:               *redisAddr,
+		RedisPassword:           *redisPassword,
+		RedisDB:                 *redisDB,
+		RedisTLS:                *redisTLS,
+		RedisCAFile:             *redisCAFile,
+		RequireClientset:        *requireClientset,
+		BuildInfo:               server.BuildInfo{Version: version, GitCommit: gitCommit, BuildDate: buildDate},
+	}, defaults)
+	if err != nil {
+		klog.Fatalf("Failed to start webhook server: %v", err)
+	}
+	if err := webhook.Run(ctx); err != nil {
+		klog.Fatalf("Failed to serve: %v", err)
+	}
+	klog.Infof("Webhook server stopped")
+	klog.Flush()
 }
 
-func (s *WebhookServer) validatePod(w http.ResponseWriter, r *http.Request) {
-	var body []byte
-	if r.Body != nil {
-		if data, err := io.ReadAll(r.Body); err == nil {
-			body = data
+// policyFromFlags builds the policy from the command line flags. It is used as
+// is when no --config file is given, and as the defaults the file overrides
+// otherwise.
+func policyFromFlags() (policy.Policy, error) {
+	defaults := policy.Policy{
+		GPUMatchMode:                *gpuMatchMode,
+		MaxGPUsPerPod:               *maxGPUsPerPod,
+		MaxMIGDevicesPerPod:         *maxMIGDevicesPerPod,
+		MaxTimeSlicedReplicasPerPod: *maxTimeSlicedReplicasPerPod,
+		MaxGPUsPerNamespace:         *maxGPUsPerNamespace,
+		GPUProductLabel:             *gpuProductLabel,
+		AllowUnspecifiedProduct:     *allowUnspecifiedProduct,
+		DenyUnlistedAccelerators:    *denyUnlistedAccelerators,
+		RequireGPULimits:            *requireGPULimits,
+		StrictZeroGPURequests:       *strictZeroGPURequests,
+		ApplyDefaults:               *applyDefaults,
+		RequiredGPULabel:            *requiredGPULabel,
+		RequiredGPULabelPattern:     *requiredGPULabelPattern,
+		Mode:                        *mode,
+		DenyMessageTemplate:         *denyMessageTemplate,
+	}
+	defaults.PodSelector = *podSelector
+	defaults.MaxGPUsAnnotation = *maxGPUsAnnotation
+	defaults.MaxGPUsAnnotationCeiling = *maxGPUsAnnotationCeiling
+	if *timeSlicedNamespaces != "" {
+		defaults.TimeSlicedNamespaces = strings.Split(*timeSlicedNamespaces, ",")
+	}
+	if *timeSlicedResources != "" {
+		defaults.TimeSlicedResources = strings.Split(*timeSlicedResources, ",")
+	}
+	if *gpuMemoryResources != "" {
+		defaults.GPUMemoryResources = strings.Split(*gpuMemoryResources, ",")
+	}
+	if *maxGPUsPerContainer >= 0 {
+		defaults.MaxGPUsPerContainer = maxGPUsPerContainer
+	}
+	if *maxGPUMemory != "" {
+		limit, err := resource.ParseQuantity(*maxGPUMemory)
+		if err != nil {
+			return policy.Policy{}, fmt.Errorf("invalid --max-gpu-memory %q: %w", *maxGPUMemory, err)
 		}
+		defaults.MaxGPUMemory = &limit
 	}
-	if len(body) == 0 {
-		http.Error(w, "empty body", http.StatusBadRequest)
-		return
+	if *minGPUContainerCPU != "" {
+		floor, err := resource.ParseQuantity(*minGPUContainerCPU)
+		if err != nil {
+			return policy.Policy{}, fmt.Errorf("invalid --min-gpu-container-cpu %q: %w", *minGPUContainerCPU, err)
+		}
+		defaults.MinGPUContainerCPU = &floor
 	}
-
-	// Decode AdmissionReview request
-	ar := v1.AdmissionReview{}
-	deserializer := s.decoder.UniversalDeserializer()
-	if _, _, err := deserializer.Decode(body, nil, &ar); err != nil {
-		http.Error(w, fmt.Sprintf("failed to decode body: %v", err), http.StatusBadRequest)
-		return
+	if *minGPUContainerMemory != "" {
+		floor, err := resource.ParseQuantity(*minGPUContainerMemory)
+		if err != nil {
+			return policy.Policy{}, fmt.Errorf("invalid --min-gpu-container-memory %q: %w", *minGPUContainerMemory, err)
+		}
+		defaults.MinGPUContainerMemory = &floor
 	}
-
-	// Process Pod
-	pod := corev1.Pod{}
-	if err := json.Unmarshal(ar.Request.Object.Raw, &pod); err != nil {
-		http.Error(w, fmt.Sprintf("failed to unmarshal pod: %v", err), http.StatusBadRequest)
-		return
+	if *gpuMemoryUnit != "" {
+		unit, err := resource.ParseQuantity(*gpuMemoryUnit)
+		if err != nil {
+			return policy.Policy{}, fmt.Errorf("invalid --gpu-memory-unit %q: %w", *gpuMemoryUnit, err)
+		}
+		defaults.GPUMemoryUnit = &unit
 	}
-
-	// Validate GPU resources
-	response := s.validateGPUResources(&pod, ar.Request.Namespace)
-	response.UID = ar.Request.UID
-
-	// Send response
-	respBytes, err := json.Marshal(v1.AdmissionReview{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "admission.k8s.io/v1",
-			Kind:       "AdmissionReview",
-		},
-		Response: response,
-	})
-	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to marshal response: %v", err), http.StatusInternalServerError)
-		return
+	if *fractionalGPUResources != "" {
+		denominators, err := parseKeyValues(*fractionalGPUResources)
+		if err != nil {
+			return policy.Policy{}, fmt.Errorf("invalid --fractional-gpu-resources: %w", err)
+		}
+		defaults.FractionalGPUResources = make(map[string]int64, len(denominators))
+		for resourceName, value := range denominators {
+			denominator, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return policy.Policy{}, fmt.Errorf("invalid --fractional-gpu-resources denominator %q for %s", value, resourceName)
+			}
+			defaults.FractionalGPUResources[resourceName] = denominator
+		}
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(respBytes)
-}
-
-func (s *WebhookServer) validateGPUResources(pod *corev1.Pod, namespace string) *v1.AdmissionResponse {
-	response := &v1.AdmissionResponse{
-		Allowed: true,
+	if *maxFractionalGPUs != "" {
+		limit, err := resource.ParseQuantity(*maxFractionalGPUs)
+		if err != nil {
+			return policy.Policy{}, fmt.Errorf("invalid --max-fractional-gpus %q: %w", *maxFractionalGPUs, err)
+		}
+		defaults.MaxFractionalGPUs = &limit
 	}
-
-	// Check each container's resource requirements
-	for _, container := range append(pod.Spec.Containers, pod.Spec.InitContainers...) {
-		for resourceName, _ := range container.Resources.Requests {
-			for _, prefix := range s.gpuPrefixes {
-				if strings.HasPrefix(string(resourceName), prefix) {
-					response.Allowed = false
-					response.Result = &metav1.Status{
-						Message: fmt.Sprintf("GPU resource %s is not allowed in namespace %s", resourceName, namespace),
-						Reason:  metav1.StatusReasonForbidden,
-					}
-					return response
-				}
+	if *allowedGPUProducts != "" {
+		defaults.AllowedProducts = strings.Split(*allowedGPUProducts, ",")
+	}
+	if *maxGPUsPerOwnerKind != "" {
+		limits, err := parseKeyValues(*maxGPUsPerOwnerKind)
+		if err != nil {
+			return policy.Policy{}, fmt.Errorf("invalid --max-gpus-per-owner-kind: %w", err)
+		}
+		defaults.MaxGPUsPerPodByOwnerKind = make(map[string]int64, len(limits))
+		for kind, value := range limits {
+			limit, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return policy.Policy{}, fmt.Errorf("invalid --max-gpus-per-owner-kind limit for %s: %w", kind, err)
+			}
+			defaults.MaxGPUsPerPodByOwnerKind[kind] = limit
+		}
+	}
+	if *allowedGPUImageRegistries != "" {
+		defaults.AllowedGPUImageRegistries = strings.Split(*allowedGPUImageRegistries, ",")
+	}
+	if *allowedRuntimeClasses != "" {
+		defaults.AllowedRuntimeClasses = strings.Split(*allowedRuntimeClasses, ",")
+	}
+	if *gpuSchedule != "" {
+		defaults.Schedule = &policy.Schedule{
+			TimeZone: *gpuScheduleTimeZone,
+			Windows:  strings.Split(*gpuSchedule, ","),
+		}
+	}
+	if *gpuNodeSelector != "" {
+		selector, err := parseKeyValues(*gpuNodeSelector)
+		if err != nil {
+			return policy.Policy{}, fmt.Errorf("invalid --gpu-node-selector: %w", err)
+		}
+		defaults.NodeSelector = selector
+	}
+	if *exemptPriorityClasses != "" {
+		defaults.ExemptPriorityClasses = strings.Split(*exemptPriorityClasses, ",")
+	}
+	if *minExemptPriority != "" {
+		priority, err := strconv.ParseInt(*minExemptPriority, 10, 32)
+		if err != nil {
+			return policy.Policy{}, fmt.Errorf("invalid --min-exempt-priority %q: %w", *minExemptPriority, err)
+		}
+		threshold := int32(priority)
+		defaults.MinExemptPriority = &threshold
+	}
+	if *gpuPrefixes != "" {
+		defaults.GPUPrefixes = strings.Split(*gpuPrefixes, ",")
+	}
+	if *gpuSuffixes != "" {
+		defaults.GPUSuffixes = strings.Split(*gpuSuffixes, ",")
+	}
+	if *deprecatedGPUPrefixes != "" {
+		defaults.DeprecatedGPUPrefixes = make(map[string]string)
+		for _, prefix := range strings.Split(*deprecatedGPUPrefixes, ",") {
+			defaults.DeprecatedGPUPrefixes[prefix] = ""
+		}
+	}
+	if *gpuNodeAffinity != "" {
+		defaults.GPUNodeAffinity = make(map[corev1.ResourceName]corev1.NodeSelectorRequirement)
+		for _, entry := range strings.Split(*gpuNodeAffinity, ";") {
+			resourceName, expression, ok := strings.Cut(entry, "=")
+			if !ok || resourceName == "" {
+				return policy.Policy{}, fmt.Errorf("invalid --gpu-node-affinity entry %q, expected resource=requirement", entry)
+			}
+			requirement, err := policy.ParseNodeRequirement(expression)
+			if err != nil {
+				return policy.Policy{}, fmt.Errorf("invalid --gpu-node-affinity entry for %s: %w", resourceName, err)
 			}
+			defaults.GPUNodeAffinity[corev1.ResourceName(resourceName)] = requirement
 		}
 	}
-	return response
+	if *skipNamespaces != "" {
+		defaults.SkipNamespaces = strings.Split(*skipNamespaces, ",")
+	}
+	if *exemptServiceAccounts != "" {
+		defaults.ExemptServiceAccounts = strings.Split(*exemptServiceAccounts, ",")
+	}
+	if err := defaults.Validate(); err != nil {
+		return policy.Policy{}, err
+	}
+	defaults.Prepare()
+	return defaults, nil
 }
 
-func (s *WebhookServer) initClientsetOrDie() {
-	config, err := clientcmd.BuildConfigFromFlags("", s.kubeconfig)
-	if err != nil {
-		klog.Fatalf("Error building kubeconfig: %s", err.Error())
-	}
-	s.clientset, err = kubernetes.NewForConfig(config)
-	if err != nil {
-		klog.Fatalf("Error building kubernetes clientset: %s", err.Error())
+// parseKeyValues parses a comma-separated list of key=value pairs.
+func parseKeyValues(s string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid pair %q, expected key=value", pair)
+		}
+		result[key] = value
 	}
-	klog.Infof("Successfully initialized kubernetes clientset")
+	return result, nil
 }
@@ -0,0 +1,31 @@
+package policy
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// acceleratorKeywords identify extended resources that look like accelerators
+// from any vendor.
+var acceleratorKeywords = []string{"gpu", "tpu", "fpga"}
+
+// isUnlistedAccelerator reports whether the resource looks like an
+// accelerator but is not covered by the configured GPU resources, e.g. a new
+// vendor's device plugin that has not been reviewed yet.
+func (p *Policy) isUnlistedAccelerator(resourceName corev1.ResourceName) bool {
+	name := strings.ToLower(string(resourceName))
+	domain, _, ok := strings.Cut(name, "/")
+	if !ok || domain == "kubernetes.io" || strings.HasSuffix(domain, ".kubernetes.io") {
+		return false
+	}
+	if p.IsGPUResource(resourceName) || p.isGPUMemoryResource(resourceName) {
+		return false
+	}
+	for _, keyword := range acceleratorKeywords {
+		if strings.Contains(name, keyword) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package policy
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestIsUnlistedAccelerator(t *testing.T) {
+	policy := Policy{GPUPrefixes: []string{"nvidia.com"}}
+	policy.Prepare()
+
+	tests := []struct {
+		resource corev1.ResourceName
+		want     bool
+	}{
+		{resource: "nvidia.com/gpu", want: false},
+		{resource: "amd.com/gpu", want: true},
+		{resource: "google.com/tpu", want: true},
+		{resource: "xilinx.com/fpga-u250", want: true},
+		{resource: "example.com/GPU-shared", want: true},
+		{resource: "hugepages-2Mi", want: false},
+		{resource: "example.com/nic", want: false},
+		{resource: "gpu.kubernetes.io/example", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.resource), func(t *testing.T) {
+			if got := policy.isUnlistedAccelerator(tt.resource); got != tt.want {
+				t.Errorf("isUnlistedAccelerator(%s) = %v, want %v", tt.resource, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// ParseNodeRequirement parses a single label selector requirement, such as
+// "gpu-pool in (amd)", "gpu-pool=amd" or "gpu-pool", into a node selector
+// requirement for GPUNodeAffinity.
+func ParseNodeRequirement(s string) (corev1.NodeSelectorRequirement, error) {
+	selector, err := labels.Parse(s)
+	if err != nil {
+		return corev1.NodeSelectorRequirement{}, fmt.Errorf("invalid node requirement %q: %w", s, err)
+	}
+	requirements, _ := selector.Requirements()
+	if len(requirements) != 1 {
+		return corev1.NodeSelectorRequirement{}, fmt.Errorf("invalid node requirement %q, expected exactly one requirement", s)
+	}
+	requirement := requirements[0]
+	switch requirement.Operator() {
+	case selection.In, selection.Equals, selection.DoubleEquals:
+		return corev1.NodeSelectorRequirement{Key: requirement.Key(), Operator: corev1.NodeSelectorOpIn, Values: requirement.ValuesUnsorted()}, nil
+	case selection.Exists:
+		return corev1.NodeSelectorRequirement{Key: requirement.Key(), Operator: corev1.NodeSelectorOpExists}, nil
+	}
+	return corev1.NodeSelectorRequirement{}, fmt.Errorf("invalid node requirement %q, only in, = and exists are supported", s)
+}
+
+func validateNodeRequirement(requirement corev1.NodeSelectorRequirement) error {
+	if requirement.Key == "" {
+		return fmt.Errorf("key must not be empty")
+	}
+	switch requirement.Operator {
+	case corev1.NodeSelectorOpIn:
+		if len(requirement.Values) == 0 {
+			return fmt.Errorf("operator In needs at least one value")
+		}
+	case corev1.NodeSelectorOpExists:
+		if len(requirement.Values) > 0 {
+			return fmt.Errorf("operator Exists takes no values")
+		}
+	default:
+		return fmt.Errorf("unsupported operator %q, must be In or Exists", requirement.Operator)
+	}
+	return nil
+}
+
+// checkGPUNodeAffinity returns the first GPU resource in GPUNodeAffinity that
+// the pod requests without being constrained to the matching nodes. ok is
+// false if the pod is denied.
+func (p *Policy) checkGPUNodeAffinity(pod *corev1.Pod) (resourceName corev1.ResourceName, requirement corev1.NodeSelectorRequirement, ok bool) {
+	names := make([]corev1.ResourceName, 0, len(p.GPUNodeAffinity))
+	for name := range p.GPUNodeAffinity {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if _, requested := findResource(pod, func(r corev1.ResourceName) bool { return r == name }); !requested {
+			continue
+		}
+		requirement := p.GPUNodeAffinity[name]
+		if !constrainedTo(pod, requirement) {
+			return name, requirement, false
+		}
+	}
+	return "", corev1.NodeSelectorRequirement{}, true
+}
+
+// constrainedTo reports whether the pod's nodeSelector or required node
+// affinity only admits nodes satisfying the requirement. Like
+// requestedGPUProducts, affinity only counts if every ORed term constrains
+// the pod.
+func constrainedTo(pod *corev1.Pod, requirement corev1.NodeSelectorRequirement) bool {
+	if value, ok := pod.Spec.NodeSelector[requirement.Key]; ok {
+		if requirement.Operator == corev1.NodeSelectorOpExists || slices.Contains(requirement.Values, value) {
+			return true
+		}
+	}
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return false
+	}
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) == 0 {
+		return false
+	}
+	for _, term := range terms {
+		if !slices.ContainsFunc(term.MatchExpressions, func(expr corev1.NodeSelectorRequirement) bool {
+			return impliesRequirement(expr, requirement)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// impliesRequirement reports whether every node matching expr also matches
+// requirement.
+func impliesRequirement(expr, requirement corev1.NodeSelectorRequirement) bool {
+	if expr.Key != requirement.Key {
+		return false
+	}
+	switch requirement.Operator {
+	case corev1.NodeSelectorOpExists:
+		return expr.Operator == corev1.NodeSelectorOpExists || (expr.Operator == corev1.NodeSelectorOpIn && len(expr.Values) > 0)
+	case corev1.NodeSelectorOpIn:
+		if expr.Operator != corev1.NodeSelectorOpIn || len(expr.Values) == 0 {
+			return false
+		}
+		for _, value := range expr.Values {
+			if !slices.Contains(requirement.Values, value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// nodeAffinityHint tells the user how to satisfy the requirement.
+func nodeAffinityHint(requirement corev1.NodeSelectorRequirement) string {
+	if requirement.Operator == corev1.NodeSelectorOpExists {
+		return fmt.Sprintf("add a required node affinity expression {key: %s, operator: Exists}", requirement.Key)
+	}
+	return fmt.Sprintf("add nodeSelector %s: %s or a required node affinity expression {key: %s, operator: In, values: [%s]}",
+		requirement.Key, requirement.Values[0], requirement.Key, strings.Join(requirement.Values, ", "))
+}
@@ -0,0 +1,93 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseNodeRequirement(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    corev1.NodeSelectorRequirement
+		wantErr bool
+	}{
+		{in: "gpu-pool in (amd)", want: corev1.NodeSelectorRequirement{Key: "gpu-pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"amd"}}},
+		{in: "gpu-pool=amd", want: corev1.NodeSelectorRequirement{Key: "gpu-pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"amd"}}},
+		{in: "gpu-pool", want: corev1.NodeSelectorRequirement{Key: "gpu-pool", Operator: corev1.NodeSelectorOpExists}},
+		{in: "gpu-pool notin (amd)", wantErr: true},
+		{in: "gpu-pool=amd,zone=a", wantErr: true},
+		{in: "in (", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseNodeRequirement(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateGPUNodeAffinity(t *testing.T) {
+	policy := Policy{
+		GPUPrefixes:         []string{"nvidia.com", "amd.com"},
+		MaxGPUsPerPod:       4,
+		MaxGPUsPerNamespace: -1,
+		Mode:                ModeEnforce,
+		GPUNodeAffinity: map[corev1.ResourceName]corev1.NodeSelectorRequirement{
+			"amd.com/gpu": {Key: "gpu-pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"amd", "amd-spot"}},
+		},
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	evaluator := newTestEvaluator(policy, testNamespace("default", nil))
+
+	required := func(terms ...[]corev1.NodeSelectorRequirement) *corev1.Affinity {
+		selector := &corev1.NodeSelector{}
+		for _, expressions := range terms {
+			selector.NodeSelectorTerms = append(selector.NodeSelectorTerms, corev1.NodeSelectorTerm{MatchExpressions: expressions})
+		}
+		return &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: selector}}
+	}
+	in := func(values ...string) []corev1.NodeSelectorRequirement {
+		return []corev1.NodeSelectorRequirement{{Key: "gpu-pool", Operator: corev1.NodeSelectorOpIn, Values: values}}
+	}
+	tests := []struct {
+		name         string
+		resource     string
+		nodeSelector map[string]string
+		affinity     *corev1.Affinity
+		wantAllowed  bool
+	}{
+		{name: "other GPU", resource: "nvidia.com/gpu", wantAllowed: true},
+		{name: "missing", resource: "amd.com/gpu"},
+		{name: "node selector", resource: "amd.com/gpu", nodeSelector: map[string]string{"gpu-pool": "amd"}, wantAllowed: true},
+		{name: "wrong node selector", resource: "amd.com/gpu", nodeSelector: map[string]string{"gpu-pool": "nvidia"}},
+		{name: "affinity", resource: "amd.com/gpu", affinity: required(in("amd-spot")), wantAllowed: true},
+		{name: "affinity too broad", resource: "amd.com/gpu", affinity: required(in("amd", "nvidia"))},
+		{name: "one term unconstrained", resource: "amd.com/gpu", affinity: required(in("amd"), nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := corev1.Pod{Spec: corev1.PodSpec{
+				NodeSelector: tt.nodeSelector,
+				Affinity:     tt.affinity,
+				Containers:   []corev1.Container{container("app", gpus(tt.resource, 1))},
+			}}
+			decision := evaluator.evaluate(&pod, "default")
+			if decision.Allowed != tt.wantAllowed {
+				t.Fatalf("allowed = %v, want %v (%s: %s)", decision.Allowed, tt.wantAllowed, decision.Reason, decision.Message)
+			}
+			want := "pod requests amd.com/gpu but is not restricted to its node pool; add nodeSelector gpu-pool: amd or a required node affinity expression {key: gpu-pool, operator: In, values: [amd, amd-spot]}"
+			if !tt.wantAllowed && (decision.Reason != ReasonGPUNodeAffinityMissing || decision.Message != want) {
+				t.Errorf("got reason=%s message=%q, want reason=%s message=%q", decision.Reason, decision.Message, ReasonGPUNodeAffinityMissing, want)
+			}
+		})
+	}
+}
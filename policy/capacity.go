@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// checkNodeCapacity compares the pod's GPU requests with the largest
+// allocatable amount of each resource on any single node. All containers of a
+// pod land on the same node, so like the scheduler the effective request of the
+// whole pod has to fit on one node. Resources no schedulable node advertises
+// are skipped, as their nodes may not have joined or reported them yet. It
+// returns a message describing the first resource that can never be
+// satisfied, or an empty string.
+func (e *Evaluator) checkNodeCapacity(ctx context.Context, policy *Policy, pod *corev1.Pod) (string, error) {
+	requests := policy.PodGPURequestsByResource(pod)
+	if len(requests) == 0 {
+		return "", nil
+	}
+	nodes, err := e.Cluster.ListNodes(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for resourceName, requested := range requests {
+		var largest int64
+		advertised := false
+		for _, node := range nodes {
+			if node.Spec.Unschedulable {
+				continue
+			}
+			if allocatable, ok := node.Status.Allocatable[resourceName]; ok {
+				largest = max(largest, allocatable.Value())
+				advertised = true
+			}
+		}
+		if advertised && requested > largest {
+			return fmt.Sprintf("pod requests %d %s but the largest node only has %d allocatable, so it can never be scheduled",
+				requested, resourceName, largest), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,78 @@
+package policy
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateNodeCapacity(t *testing.T) {
+	node := func(name string, count int64, unschedulable bool) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       corev1.NodeSpec{Unschedulable: unschedulable},
+			Status:     corev1.NodeStatus{Allocatable: gpus("nvidia.com/gpu", count)},
+		}
+	}
+	evaluator := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: -1, MaxMIGDevicesPerPod: -1, MaxGPUsPerNamespace: -1},
+		testNamespace("ml", map[string]string{"gpu-policy/allowed": "true"}),
+		node("a100-1", 8, false),
+		node("h100-1", 4, false),
+		node("cordoned", 16, true))
+	evaluator.CheckNodeCapacity = true
+
+	tests := []struct {
+		name        string
+		spec        corev1.PodSpec
+		wantAllowed bool
+	}{
+		{
+			name:        "fits the largest node",
+			spec:        corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 8))}},
+			wantAllowed: true,
+		},
+		{
+			name: "exceeds the largest node",
+			spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 16))}},
+		},
+		{
+			name: "containers summed on one node",
+			spec: corev1.PodSpec{Containers: []corev1.Container{
+				container("a", gpus("nvidia.com/gpu", 6)),
+				container("b", gpus("nvidia.com/gpu", 6)),
+			}},
+		},
+		{
+			name: "init container not added to regular containers",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{container("init", gpus("nvidia.com/gpu", 8))},
+				Containers:     []corev1.Container{container("app", gpus("nvidia.com/gpu", 8))},
+			},
+			wantAllowed: true,
+		},
+		{
+			name:        "resource no node advertises",
+			spec:        corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/mig-1g.5gb", 1))}},
+			wantAllowed: true,
+		},
+		{
+			name: "advertised resource checked alongside one no node advertises",
+			spec: corev1.PodSpec{Containers: []corev1.Container{
+				container("a", gpus("nvidia.com/mig-1g.5gb", 1)),
+				container("b", gpus("nvidia.com/gpu", 16)),
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := evaluator.evaluate(&corev1.Pod{Spec: tt.spec}, "ml")
+			if decision.Allowed != tt.wantAllowed {
+				t.Fatalf("got allowed=%v reason=%s (%s), want allowed=%v", decision.Allowed, decision.Reason, decision.Message, tt.wantAllowed)
+			}
+			if !tt.wantAllowed && decision.Reason != ReasonExceedsNodeCapacity {
+				t.Errorf("Reason = %s, want %s", decision.Reason, ReasonExceedsNodeCapacity)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package policy
+
+import corev1 "k8s.io/api/core/v1"
+
+// DefaultedPod returns a copy of the pod with the resource defaults the API
+// server applies: every resource with a limit but no request is requested at
+// its limit. The webhook may see the pod before or after this defaulting
+// depending on how the pod was created, so applying it again evaluates the
+// resources the pod is scheduled with. Ephemeral containers are left alone:
+// the API server rejects resources on them, and any GPU they name is still
+// counted with the larger of request and limit.
+func DefaultedPod(pod *corev1.Pod) *corev1.Pod {
+	pod = pod.DeepCopy()
+	for i := range pod.Spec.Containers {
+		defaultRequests(&pod.Spec.Containers[i].Resources)
+	}
+	for i := range pod.Spec.InitContainers {
+		defaultRequests(&pod.Spec.InitContainers[i].Resources)
+	}
+	if pod.Spec.Resources != nil {
+		defaultRequests(pod.Spec.Resources)
+	}
+	return pod
+}
+
+func defaultRequests(resources *corev1.ResourceRequirements) {
+	for resourceName, limit := range resources.Limits {
+		if _, ok := resources.Requests[resourceName]; ok {
+			continue
+		}
+		if resources.Requests == nil {
+			resources.Requests = make(corev1.ResourceList, len(resources.Limits))
+		}
+		resources.Requests[resourceName] = limit.DeepCopy()
+	}
+}
@@ -0,0 +1,74 @@
+package policy
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestDefaultedPod(t *testing.T) {
+	limitsOnly := corev1.ResourceRequirements{Limits: corev1.ResourceList{
+		"nvidia.com/gpu":      resource.MustParse("2"),
+		corev1.ResourceMemory: resource.MustParse("1Gi"),
+	}}
+	partial := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+		Limits: corev1.ResourceList{
+			"nvidia.com/gpu":      resource.MustParse("1"),
+			corev1.ResourceMemory: resource.MustParse("1Gi"),
+		},
+	}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "init", Resources: limitsOnly}},
+		Containers:     []corev1.Container{{Name: "app", Resources: partial}},
+		Resources:      &corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")}},
+	}}
+	original := pod.DeepCopy()
+
+	got := DefaultedPod(pod)
+	if !apiequality.Semantic.DeepEqual(pod, original) {
+		t.Errorf("DefaultedPod modified its argument")
+	}
+	tests := []struct {
+		name string
+		got  corev1.ResourceList
+		want corev1.ResourceList
+	}{
+		{name: "limits only", got: got.Spec.InitContainers[0].Resources.Requests, want: limitsOnly.Limits},
+		{
+			name: "request kept",
+			got:  got.Spec.Containers[0].Resources.Requests,
+			want: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("512Mi")},
+		},
+		{name: "pod level", got: got.Spec.Resources.Requests, want: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !apiequality.Semantic.DeepEqual(tt.got, tt.want) {
+				t.Errorf("requests = %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultedPodSkipsEphemeralContainers(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{{Name: "app"}},
+		EphemeralContainers: []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:      "debug",
+			Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")}},
+		}}},
+	}}
+
+	got := DefaultedPod(pod)
+	if requests := got.Spec.EphemeralContainers[0].Resources.Requests; requests != nil {
+		t.Errorf("ephemeral container requests = %v, want none", requests)
+	}
+	policy := Policy{GPUPrefixes: []string{"nvidia.com"}}
+	policy.Prepare()
+	if gpus := policy.PodGPURequests(got); gpus != 1 {
+		t.Errorf("PodGPURequests() = %d, want the ephemeral container's limit of 1", gpus)
+	}
+}
@@ -0,0 +1,34 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DeprecatedGPUMessage returns the message denying a new pod that requests a
+// resource under one of the DeprecatedGPUPrefixes. It only applies to pods
+// being created, so that existing pods keep working during a migration.
+func (p *Policy) DeprecatedGPUMessage(pod *corev1.Pod) (string, bool) {
+	if len(p.DeprecatedGPUPrefixes) == 0 {
+		return "", false
+	}
+	var prefix string
+	resourceName, found := findResource(pod, func(resourceName corev1.ResourceName) bool {
+		for candidate := range p.DeprecatedGPUPrefixes {
+			if strings.HasPrefix(string(resourceName), candidate) {
+				prefix = candidate
+				return true
+			}
+		}
+		return false
+	})
+	if !found {
+		return "", false
+	}
+	if message := p.DeprecatedGPUPrefixes[prefix]; message != "" {
+		return message, true
+	}
+	return fmt.Sprintf("GPU resource %s is deprecated and no longer admitted for new pods", resourceName), true
+}
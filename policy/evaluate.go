@@ -0,0 +1,372 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// Cluster provides the cluster state some checks depend on. Implementations
+// decide whether lookups are served from a cache or the API server.
+type Cluster interface {
+	GetNamespace(ctx context.Context, name string) (*corev1.Namespace, error)
+	ListPods(ctx context.Context, namespace string) ([]*corev1.Pod, error)
+	ListResourceQuotas(ctx context.Context, namespace string) ([]corev1.ResourceQuota, error)
+	ListLimitRanges(ctx context.Context, namespace string) ([]corev1.LimitRange, error)
+	GetPriorityClass(ctx context.Context, name string) (*schedulingv1.PriorityClass, error)
+	ListNodes(ctx context.Context) ([]*corev1.Node, error)
+}
+
+// Decision is the outcome of evaluating a pod against a policy.
+type Decision struct {
+	Allowed bool
+	// Reason is one of the Reason constants and is reported in metrics.
+	Reason string
+	// Message explains a denial.
+	Message string
+	// Warnings are returned to the client for allowed pods, e.g. violations
+	// in warn mode.
+	Warnings []string
+	// Causes describe a denial in machine-readable form, e.g. the resource
+	// that exceeded a per-pod limit.
+	Causes []metav1.StatusCause
+	// Exemption names the rule that let a GPU pod bypass the policy, e.g.
+	// exemptServiceAccounts contains team/ci. Empty unless the pod was
+	// exempted.
+	Exemption string
+}
+
+func allow(reason string) Decision {
+	return Decision{Allowed: true, Reason: reason}
+}
+
+func exempt(reason, rule string) Decision {
+	return Decision{Allowed: true, Reason: reason, Exemption: rule}
+}
+
+func deny(reason, message string) Decision {
+	return Decision{Reason: reason, Message: message}
+}
+
+// denyLimit denies a pod requesting more of resourceName than the per-pod
+// limit, naming the resource and the limit in the decision's causes.
+func denyLimit(reason, message string, resourceNames []corev1.ResourceName, limit string) Decision {
+	if len(resourceNames) > 1 {
+		message += fmt.Sprintf(" (requested as %s)", strings.Join(resourceNameStrings(resourceNames), ", "))
+	}
+	decision := deny(reason, message)
+	for _, resourceName := range resourceNames {
+		decision.Causes = append(decision.Causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   fmt.Sprintf("spec.containers[*].resources.requests[%s]", resourceName),
+			Message: "must be less than or equal to " + limit,
+		})
+	}
+	return decision
+}
+
+// Evaluator evaluates pods against a policy.
+type Evaluator struct {
+	Cluster Cluster
+	// AllowLabelKey and AllowLabelValue form the namespace label that opts a
+	// namespace in to GPUs beyond the per-pod limit.
+	AllowLabelKey   string
+	AllowLabelValue string
+	// FailOpen allows pods whose checks fail because a lookup failed.
+	FailOpen bool
+	// CheckResourceQuota denies pods that would exceed a ResourceQuota.
+	CheckResourceQuota bool
+	// ApplyLimitRangeDefaults evaluates pods with the container defaults of
+	// their namespace's LimitRanges applied.
+	ApplyLimitRangeDefaults bool
+	// CheckNodeCapacity denies pods requesting more GPUs than any single
+	// node can allocate.
+	CheckNodeCapacity bool
+	// Now returns the time schedules are checked against. Nil uses time.Now.
+	Now func() time.Time
+	// Policies caches the resolved policies of namespaces. Nil looks the
+	// namespace up for every request that needs it.
+	Policies PolicyCache
+}
+
+// templateKey marks a context as evaluating a pod template.
+type templateKey struct{}
+
+// ForTemplate returns a context under which Evaluate treats the pod as the
+// template of a workload rather than a pod about to be created. The
+// namespace GPU quota and ResourceQuota checks are skipped: the namespace
+// usage already includes the workload's running pods, so an unchanged apply
+// or a scale of a workload in a namespace at quota would otherwise be denied.
+// The pods themselves are still checked against the quotas when created.
+func ForTemplate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, templateKey{}, true)
+}
+
+func isTemplate(ctx context.Context) bool {
+	template, _ := ctx.Value(templateKey{}).(bool)
+	return template
+}
+
+// Evaluate evaluates the pod against policy and applies the deny message
+// template and warn mode to the result.
+func (e *Evaluator) Evaluate(ctx context.Context, policy *Policy, pod *corev1.Pod, namespace string) Decision {
+	policy = policy.ForNamespace(namespace)
+	pod, decision, ok := e.applyLimitRanges(ctx, policy, pod, namespace)
+	if ok {
+		if policy.ApplyDefaults {
+			pod = DefaultedPod(pod)
+		}
+		decision = e.evaluate(ctx, policy, pod, namespace)
+	}
+	if decision.Allowed {
+		return decision
+	}
+	resourceNames := resourceNameStrings(policy.GPUResources(pod))
+	decision.Message = policy.denyMessage(denyMessageData{
+		Namespace: namespace,
+		PodName:   PodDisplayName(pod),
+		Resource:  strings.Join(resourceNames, ", "),
+		Resources: resourceNames,
+		Limit:     policy.MaxGPUsFor(pod, namespace),
+		Reason:    decision.Reason,
+		Message:   decision.Message,
+	})
+	if policy.Mode == ModeWarn {
+		decision.Allowed = true
+		decision.Warnings = append(decision.Warnings, decision.Message)
+		decision.Message = ""
+	}
+	return decision
+}
+
+func (e *Evaluator) evaluate(ctx context.Context, policy *Policy, pod *corev1.Pod, namespace string) Decision {
+	if policy.IsSkippedNamespace(namespace) {
+		return allow(ReasonNamespaceSkipped)
+	}
+	// Pods without GPUs are allowed below anyway, so only look the namespace
+	// up for pods the policy could deny.
+	_, requestsGPU := policy.FindGPUResource(pod)
+	if (requestsGPU || policy.DenyUnlistedAccelerators) && e.enforcementDisabled(ctx, policy, namespace) {
+		klog.Warningf("GPU policy enforcement is BYPASSED in namespace %s by %s=true", namespace, DisabledAnnotation)
+		return exempt(ReasonEnforcementDisabled, fmt.Sprintf("namespace annotation %s=true", DisabledAnnotation))
+	}
+	if !policy.selectsPod(pod) {
+		return allow(ReasonPodNotSelected)
+	}
+	if policy.DenyUnlistedAccelerators {
+		switch resourceNames := findResources(pod, policy.isUnlistedAccelerator); len(resourceNames) {
+		case 0:
+		case 1:
+			return deny(ReasonUnlistedAccelerator, fmt.Sprintf("resource %s looks like an accelerator but is not in the approved GPU resources", resourceNames[0]))
+		default:
+			return deny(ReasonUnlistedAccelerator, fmt.Sprintf("resources %s look like accelerators but are not in the approved GPU resources", strings.Join(resourceNameStrings(resourceNames), ", ")))
+		}
+	}
+	if !requestsGPU {
+		return allow(ReasonNoGPU)
+	}
+	// Exempt pods bypass every check, including the shape of their requests.
+	if policy.IsExemptServiceAccount(pod, namespace) {
+		return exempt(ReasonExemptServiceAccount, fmt.Sprintf("exemptServiceAccounts contains %s/%s", namespace, serviceAccountName(pod)))
+	}
+	if rule, ok := e.exemptPriority(ctx, policy, pod); ok {
+		return exempt(ReasonExemptPriority, rule)
+	}
+	if err := policy.checkGPULimitsSet(pod); err != nil {
+		return deny(ReasonGPULimitsMissing, err.Error())
+	}
+	if err := policy.checkLimitsMatchRequests(pod); err != nil {
+		return deny(ReasonLimitMismatch, err.Error())
+	}
+	if err := policy.checkMinResources(pod); err != nil {
+		return deny(ReasonMinResourcesNotMet, err.Error())
+	}
+	if schedule := policy.scheduleFor(namespace); schedule != nil {
+		now := time.Now
+		if e.Now != nil {
+			now = e.Now
+		}
+		if t := now(); !schedule.allows(t) {
+			return deny(ReasonOutsideSchedule, fmt.Sprintf("GPU pods are not admitted in namespace %s at this time, GPUs are available again from %s",
+				namespace, schedule.nextOpening(t).Format("Mon 2006-01-02 15:04 MST")))
+		}
+	}
+	if err := policy.checkGPUImageRegistries(pod); err != nil {
+		return deny(ReasonImageRegistryNotAllowed, err.Error())
+	}
+	if err := policy.checkRuntimeClass(pod); err != nil {
+		return deny(ReasonRuntimeClassNotAllowed, err.Error())
+	}
+	if err := policy.checkRequiredLabel(pod); err != nil {
+		return deny(ReasonRequiredLabelMissing, err.Error())
+	}
+	if product, ok := policy.checkGPUProducts(pod, namespace); !ok {
+		if product == "" {
+			return deny(ReasonGPUProductNotAllowed, fmt.Sprintf("GPU pods in namespace %s must select an allowed GPU product via %s", namespace, policy.productLabel()))
+		}
+		return deny(ReasonGPUProductNotAllowed, fmt.Sprintf("GPU product %s is not allowed in namespace %s", product, namespace))
+	}
+	if resourceName, requirement, ok := policy.checkGPUNodeAffinity(pod); !ok {
+		return deny(ReasonGPUNodeAffinityMissing, fmt.Sprintf("pod requests %s but is not restricted to its node pool; %s", resourceName, nodeAffinityHint(requirement)))
+	}
+
+	if policy.separateMIG() {
+		if migTotal := policy.PodMIGRequests(pod); migTotal > policy.MaxMIGDevicesPerPod {
+			resourceNames := findResources(pod, func(resourceName corev1.ResourceName) bool {
+				return policy.IsGPUResource(resourceName) && isMIGResource(resourceName)
+			})
+			return denyLimit(ReasonMaxMIGExceeded, fmt.Sprintf("pod requests %d MIG devices, exceeding the limit of %d per pod", migTotal, policy.MaxMIGDevicesPerPod),
+				resourceNames, strconv.FormatInt(policy.MaxMIGDevicesPerPod, 10))
+		}
+	}
+
+	if policy.separateTimeSlicing() {
+		if replicas := policy.PodTimeSlicedReplicas(pod, namespace); replicas > policy.MaxTimeSlicedReplicasPerPod {
+			resourceNames := findResources(pod, policy.isTimeSlicedResource)
+			if len(resourceNames) == 0 {
+				resourceNames = findResources(pod, policy.isFullGPUResource)
+			}
+			return denyLimit(ReasonMaxTimeSlicedExceeded, fmt.Sprintf("pod requests %d time-sliced GPU replicas, exceeding the limit of %d per pod", replicas, policy.MaxTimeSlicedReplicasPerPod),
+				resourceNames, strconv.FormatInt(policy.MaxTimeSlicedReplicasPerPod, 10))
+		}
+	}
+
+	if policy.MaxGPUMemory != nil {
+		memory, ok := policy.podGPUMemory(pod)
+		if !ok {
+			return denyLimit(ReasonMaxGPUMemoryExceeded, fmt.Sprintf("pod requests more GPU memory than can be evaluated, exceeding the limit of %s per pod", policy.MaxGPUMemory.String()),
+				findResources(pod, policy.isGPUMemoryResource), policy.MaxGPUMemory.String())
+		}
+		if memory > policy.MaxGPUMemory.Value() {
+			requested := resource.NewQuantity(memory, resource.BinarySI)
+			return denyLimit(ReasonMaxGPUMemoryExceeded, fmt.Sprintf("pod requests %s of GPU memory, exceeding the limit of %s per pod", requested.String(), policy.MaxGPUMemory.String()),
+				findResources(pod, policy.isGPUMemoryResource), policy.MaxGPUMemory.String())
+		}
+	}
+
+	if policy.MaxFractionalGPUs != nil {
+		if milli := policy.podFractionalMilliGPUs(pod); milli > policy.MaxFractionalGPUs.MilliValue() {
+			limit := formatMilliGPUs(policy.MaxFractionalGPUs.MilliValue())
+			return denyLimit(ReasonMaxFractionalGPUsExceeded, fmt.Sprintf("pod requests %s GPUs through fractional GPU resources, exceeding the limit of %s per pod", formatMilliGPUs(milli), limit),
+				findResources(pod, policy.isFractionalGPUResource), limit)
+		}
+	}
+
+	if _, _, err := policy.annotatedMaxGPUs(pod); err != nil {
+		return deny(ReasonInvalidMaxGPUsAnnotation, err.Error())
+	}
+
+	if name, field, gpus, found := policy.containerOverGPULimit(pod); found && !policy.isTimeSlicedNamespace(namespace) {
+		limit := strconv.FormatInt(*policy.MaxGPUsPerContainer, 10)
+		decision := deny(ReasonMaxGPUsPerContainerExceeded, fmt.Sprintf("container %s requests %d GPUs, exceeding the limit of %s per container", name, gpus, limit))
+		decision.Causes = []metav1.StatusCause{{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Field:   field,
+			Message: "must be less than or equal to " + limit,
+		}}
+		return decision
+	}
+
+	decision := allow(ReasonWithinLimit)
+	fullGPUs := findResources(pod, policy.isFullGPUResource)
+	requestsFullGPU := len(fullGPUs) > 0
+	if policy.isTimeSlicedNamespace(namespace) {
+		// Already limited as time-sliced replicas above.
+		requestsFullGPU = false
+	}
+	total := policy.PodGPURequests(pod)
+	limit := policy.MaxGPUsFor(pod, namespace)
+	if requestsFullGPU && (limit < 0 || total > limit) {
+		allowed, err := e.namespaceAllowsGPU(ctx, policy, namespace)
+		if err != nil {
+			klog.FromContext(ctx).Error(err, "Failed to get namespace", "namespace", namespace)
+			if e.FailOpen {
+				return allow(ReasonNamespaceLookupFailed)
+			}
+			return deny(ReasonNamespaceLookupFailed, fmt.Sprintf("unable to verify GPU policy for namespace %s: %v", namespace, err))
+		}
+		if !allowed {
+			if limit >= 0 {
+				return denyLimit(ReasonMaxGPUsExceeded, fmt.Sprintf("pod requests %d GPUs, exceeding the limit of %d per pod in namespace %s", total, limit, namespace),
+					fullGPUs, strconv.FormatInt(limit, 10))
+			}
+			if len(fullGPUs) > 1 {
+				return deny(ReasonGPUNotAllowed, fmt.Sprintf("GPU resources %s are not allowed in namespace %s", strings.Join(resourceNameStrings(fullGPUs), ", "), namespace))
+			}
+			return deny(ReasonGPUNotAllowed, fmt.Sprintf("GPU resource %s is not allowed in namespace %s", fullGPUs[0], namespace))
+		}
+		decision = exempt(ReasonNamespaceAllowed, fmt.Sprintf("namespace label %s=%s", e.AllowLabelKey, e.AllowLabelValue))
+	}
+
+	if quota := policy.namespaceQuotaFor(namespace); quota >= 0 && !isTemplate(ctx) {
+		used, err := e.namespaceGPUUsage(ctx, policy, pod, namespace)
+		if err != nil {
+			klog.FromContext(ctx).Error(err, "Failed to list pods", "namespace", namespace)
+			if e.FailOpen {
+				return allow(ReasonQuotaLookupFailed)
+			}
+			return deny(ReasonQuotaLookupFailed, fmt.Sprintf("unable to verify GPU quota for namespace %s: %v", namespace, err))
+		}
+		if used+total > quota {
+			return deny(ReasonNamespaceQuotaExceeded, fmt.Sprintf("pod requests %d GPUs but namespace %s already uses %d of its %d GPU quota", total, namespace, used, quota))
+		}
+	}
+
+	if e.CheckResourceQuota && !isTemplate(ctx) {
+		message, err := e.checkResourceQuotas(ctx, policy, pod, namespace)
+		if err != nil {
+			klog.FromContext(ctx).Error(err, "Failed to list resource quotas", "namespace", namespace)
+			if e.FailOpen {
+				return allow(ReasonQuotaLookupFailed)
+			}
+			return deny(ReasonQuotaLookupFailed, fmt.Sprintf("unable to verify resource quota for namespace %s: %v", namespace, err))
+		}
+		if message != "" {
+			return deny(ReasonResourceQuotaExceeded, message)
+		}
+	}
+
+	if e.CheckNodeCapacity {
+		message, err := e.checkNodeCapacity(ctx, policy, pod)
+		if err != nil {
+			klog.FromContext(ctx).Error(err, "Failed to list nodes")
+			if e.FailOpen {
+				return allow(ReasonNodeLookupFailed)
+			}
+			return deny(ReasonNodeLookupFailed, fmt.Sprintf("unable to verify GPU node capacity: %v", err))
+		}
+		if message != "" {
+			return deny(ReasonExceedsNodeCapacity, message)
+		}
+	}
+	return decision
+}
+
+// enforcementDisabled reports whether the namespace carries the kill switch
+// annotation. A failed lookup leaves enforcement on; the checks that need the
+// namespace apply the fail-open setting themselves.
+func (e *Evaluator) enforcementDisabled(ctx context.Context, policy *Policy, namespace string) bool {
+	resolved, err := e.resolve(ctx, policy, namespace)
+	if err != nil {
+		klog.FromContext(ctx).V(2).Info("Failed to get namespace to check annotation", "namespace", namespace, "annotation", DisabledAnnotation, "err", err)
+		return false
+	}
+	return resolved.Disabled
+}
+
+// namespaceAllowsGPU reports whether the namespace carries the opt-in label.
+func (e *Evaluator) namespaceAllowsGPU(ctx context.Context, policy *Policy, namespace string) (bool, error) {
+	resolved, err := e.resolve(ctx, policy, namespace)
+	if err != nil {
+		return false, err
+	}
+	return resolved.AllowsGPU, nil
+}
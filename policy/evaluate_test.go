@@ -0,0 +1,1040 @@
+package policy
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+)
+
+// fakeCluster serves the objects a test was set up with.
+type fakeCluster []runtime.Object
+
+func (c fakeCluster) GetNamespace(ctx context.Context, name string) (*corev1.Namespace, error) {
+	for _, obj := range c {
+		if ns, ok := obj.(*corev1.Namespace); ok && ns.Name == name {
+			return ns, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("namespaces"), name)
+}
+
+func (c fakeCluster) ListPods(ctx context.Context, namespace string) ([]*corev1.Pod, error) {
+	var pods []*corev1.Pod
+	for _, obj := range c {
+		if pod, ok := obj.(*corev1.Pod); ok && pod.Namespace == namespace {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+func (c fakeCluster) ListResourceQuotas(ctx context.Context, namespace string) ([]corev1.ResourceQuota, error) {
+	var quotas []corev1.ResourceQuota
+	for _, obj := range c {
+		if quota, ok := obj.(*corev1.ResourceQuota); ok && quota.Namespace == namespace {
+			quotas = append(quotas, *quota)
+		}
+	}
+	return quotas, nil
+}
+
+func (c fakeCluster) ListLimitRanges(ctx context.Context, namespace string) ([]corev1.LimitRange, error) {
+	var limitRanges []corev1.LimitRange
+	for _, obj := range c {
+		if limitRange, ok := obj.(*corev1.LimitRange); ok && limitRange.Namespace == namespace {
+			limitRanges = append(limitRanges, *limitRange)
+		}
+	}
+	return limitRanges, nil
+}
+
+func (c fakeCluster) GetPriorityClass(ctx context.Context, name string) (*schedulingv1.PriorityClass, error) {
+	for _, obj := range c {
+		if class, ok := obj.(*schedulingv1.PriorityClass); ok && class.Name == name {
+			return class, nil
+		}
+	}
+	return nil, apierrors.NewNotFound(schedulingv1.Resource("priorityclasses"), name)
+}
+
+func (c fakeCluster) ListNodes(ctx context.Context) ([]*corev1.Node, error) {
+	var nodes []*corev1.Node
+	for _, obj := range c {
+		if node, ok := obj.(*corev1.Node); ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+// testEvaluator evaluates pods against a single policy.
+type testEvaluator struct {
+	*Evaluator
+	policy *Policy
+}
+
+func newTestEvaluator(policy Policy, objects ...runtime.Object) *testEvaluator {
+	return &testEvaluator{
+		Evaluator: &Evaluator{
+			Cluster:         fakeCluster(objects),
+			AllowLabelKey:   "gpu-policy/allowed",
+			AllowLabelValue: "true",
+		},
+		policy: &policy,
+	}
+}
+
+func (e *testEvaluator) evaluate(pod *corev1.Pod, namespace string) Decision {
+	return e.Evaluate(context.Background(), e.policy, pod, namespace)
+}
+
+func testNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+	}
+}
+
+func container(name string, resources corev1.ResourceList) corev1.Container {
+	return corev1.Container{
+		Name: name,
+		Resources: corev1.ResourceRequirements{
+			Requests: resources,
+			Limits:   resources,
+		},
+	}
+}
+
+// sidecar returns a restartable init container requesting resources.
+func sidecar(name string, resources corev1.ResourceList) corev1.Container {
+	always := corev1.ContainerRestartPolicyAlways
+	c := container(name, resources)
+	c.RestartPolicy = &always
+	return c
+}
+
+func gpus(resourceName string, count int64) corev1.ResourceList {
+	return corev1.ResourceList{
+		corev1.ResourceName(resourceName): *resource.NewQuantity(count, resource.DecimalSI),
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	cpu := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}
+
+	tests := []struct {
+		name        string
+		prefixes    []string
+		pod         corev1.Pod
+		wantAllowed bool
+		wantMessage string
+	}{
+		{
+			name:        "no GPU",
+			prefixes:    []string{"nvidia.com"},
+			pod:         corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", cpu)}}},
+			wantAllowed: true,
+		},
+		{
+			name:        "nvidia GPU",
+			prefixes:    []string{"nvidia.com"},
+			pod:         corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 1))}}},
+			wantMessage: "GPU resource nvidia.com/gpu is not allowed in namespace default",
+		},
+		{
+			name:        "amd GPU with multiple prefixes",
+			prefixes:    []string{"nvidia.com", "amd.com"},
+			pod:         corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("amd.com/gpu", 1))}}},
+			wantMessage: "GPU resource amd.com/gpu is not allowed in namespace default",
+		},
+		{
+			name:        "amd GPU without its prefix",
+			prefixes:    []string{"nvidia.com"},
+			pod:         corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("amd.com/gpu", 1))}}},
+			wantAllowed: true,
+		},
+		{
+			name:     "GPU only in init container",
+			prefixes: []string{"nvidia.com"},
+			pod: corev1.Pod{Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{container("init", gpus("nvidia.com/gpu", 1))},
+				Containers:     []corev1.Container{container("app", cpu)},
+			}},
+			wantMessage: "GPU resource nvidia.com/gpu is not allowed in namespace default",
+		},
+		{
+			name:     "GPU in sidecar",
+			prefixes: []string{"nvidia.com"},
+			pod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				container("app", cpu),
+				container("sidecar", gpus("nvidia.com/gpu", 1)),
+			}}},
+			wantMessage: "GPU resource nvidia.com/gpu is not allowed in namespace default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator := newTestEvaluator(Policy{GPUPrefixes: tt.prefixes, MaxGPUsPerPod: -1, MaxGPUsPerNamespace: -1}, testNamespace("default", nil))
+			decision := evaluator.evaluate(&tt.pod, "default")
+			if decision.Allowed != tt.wantAllowed {
+				t.Fatalf("Allowed = %v, want %v", decision.Allowed, tt.wantAllowed)
+			}
+			if decision.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", decision.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestEvaluateNamespaceOptIn(t *testing.T) {
+	evaluator := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: -1, MaxGPUsPerNamespace: -1},
+		testNamespace("ml", map[string]string{"gpu-policy/allowed": "true"}))
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 1))}}}
+
+	decision := evaluator.evaluate(&pod, "ml")
+	if !decision.Allowed {
+		t.Fatalf("expected pod to be allowed in opted-in namespace, got %q", decision.Message)
+	}
+	if decision.Reason != ReasonNamespaceAllowed {
+		t.Errorf("Reason = %q, want %q", decision.Reason, ReasonNamespaceAllowed)
+	}
+}
+
+func TestEvaluateExemptionRule(t *testing.T) {
+	minPriority, priority := int32(1000), int32(2000)
+	base := Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 0, MaxGPUsPerNamespace: -1, MaxGPUsAnnotationCeiling: -1}
+	disabled := testNamespace("incident", nil)
+	disabled.Annotations = map[string]string{DisabledAnnotation: "true"}
+	tests := []struct {
+		name       string
+		policy     func(*Policy)
+		namespace  string
+		pod        func(*corev1.Pod)
+		wantReason string
+		wantRule   string
+	}{
+		{name: "not exempt", namespace: "team", wantReason: ReasonMaxGPUsExceeded},
+		{name: "namespace label", namespace: "ml", wantReason: ReasonNamespaceAllowed, wantRule: "namespace label gpu-policy/allowed=true"},
+		{name: "namespace annotation", namespace: "incident", wantReason: ReasonEnforcementDisabled, wantRule: "namespace annotation " + DisabledAnnotation + "=true"},
+		{
+			name:       "service account",
+			policy:     func(p *Policy) { p.ExemptServiceAccounts = []string{"team/ci"} },
+			namespace:  "team",
+			pod:        func(pod *corev1.Pod) { pod.Spec.ServiceAccountName = "ci" },
+			wantReason: ReasonExemptServiceAccount,
+			wantRule:   "exemptServiceAccounts contains team/ci",
+		},
+		{
+			name:       "priority class",
+			policy:     func(p *Policy) { p.ExemptPriorityClasses = []string{"critical"} },
+			namespace:  "team",
+			pod:        func(pod *corev1.Pod) { pod.Spec.PriorityClassName = "critical" },
+			wantReason: ReasonExemptPriority,
+			wantRule:   "exemptPriorityClasses contains critical",
+		},
+		{
+			name:       "priority",
+			policy:     func(p *Policy) { p.MinExemptPriority = &minPriority },
+			namespace:  "team",
+			pod:        func(pod *corev1.Pod) { pod.Spec.Priority = &priority },
+			wantReason: ReasonExemptPriority,
+			wantRule:   "priority 2000 reaches minExemptPriority 1000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := base
+			if tt.policy != nil {
+				tt.policy(&p)
+			}
+			evaluator := newTestEvaluator(p,
+				testNamespace("team", nil),
+				testNamespace("ml", map[string]string{"gpu-policy/allowed": "true"}),
+				disabled)
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 1))}}}
+			if tt.pod != nil {
+				tt.pod(&pod)
+			}
+
+			decision := evaluator.evaluate(&pod, tt.namespace)
+			if decision.Reason != tt.wantReason || decision.Exemption != tt.wantRule {
+				t.Errorf("got reason=%s exemption=%q, want reason=%s exemption=%q", decision.Reason, decision.Exemption, tt.wantReason, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestEvaluateSkipNamespaces(t *testing.T) {
+	evaluator := newTestEvaluator(Policy{
+		GPUPrefixes:         []string{"nvidia.com"},
+		MaxGPUsPerPod:       0,
+		MaxGPUsPerNamespace: -1,
+		SkipNamespaces:      []string{"kube-system"},
+	}, testNamespace("kube-system", nil), testNamespace("team", nil))
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("device-plugin", gpus("nvidia.com/gpu", 1))}}}
+
+	if decision := evaluator.evaluate(&pod, "kube-system"); !decision.Allowed || decision.Reason != ReasonNamespaceSkipped {
+		t.Errorf("kube-system: got allowed=%v reason=%s, want allowed with %s", decision.Allowed, decision.Reason, ReasonNamespaceSkipped)
+	}
+	if decision := evaluator.evaluate(&pod, "team"); decision.Allowed {
+		t.Errorf("team: expected pod to be denied")
+	}
+}
+
+func TestEvaluateRequiredLabel(t *testing.T) {
+	policy := Policy{
+		GPUPrefixes:             []string{"nvidia.com"},
+		MaxGPUsPerPod:           1,
+		MaxGPUsPerNamespace:     -1,
+		RequiredGPULabel:        "finance/cost-center",
+		RequiredGPULabelPattern: "cc-[0-9]+",
+		Mode:                    ModeEnforce,
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	policy.Prepare()
+	evaluator := newTestEvaluator(policy, testNamespace("default", nil))
+
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		resources   corev1.ResourceList
+		wantAllowed bool
+	}{
+		{name: "labeled", labels: map[string]string{"finance/cost-center": "cc-42"}, resources: gpus("nvidia.com/gpu", 1), wantAllowed: true},
+		{name: "missing", resources: gpus("nvidia.com/gpu", 1)},
+		{name: "not matching", labels: map[string]string{"finance/cost-center": "cc-42-tmp"}, resources: gpus("nvidia.com/gpu", 1)},
+		{name: "no GPU", wantAllowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Labels: tt.labels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{container("app", tt.resources)}},
+			}
+			decision := evaluator.evaluate(&pod, "default")
+			if decision.Allowed != tt.wantAllowed {
+				t.Errorf("allowed = %v, want %v (%s: %s)", decision.Allowed, tt.wantAllowed, decision.Reason, decision.Message)
+			}
+			if !tt.wantAllowed && decision.Reason != ReasonRequiredLabelMissing {
+				t.Errorf("reason = %s, want %s", decision.Reason, ReasonRequiredLabelMissing)
+			}
+		})
+	}
+}
+
+func TestEvaluateRequireGPULimits(t *testing.T) {
+	requestsOnly := corev1.Container{Name: "app", Resources: corev1.ResourceRequirements{Requests: gpus("nvidia.com/gpu", 1)}}
+	cpuLimitOnly := corev1.Container{Name: "app", Resources: corev1.ResourceRequirements{
+		Requests: gpus("nvidia.com/gpu", 1),
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+	}}
+	tests := []struct {
+		name        string
+		require     bool
+		container   corev1.Container
+		wantAllowed bool
+		wantReason  string
+		wantMessage string
+	}{
+		{name: "limits set", require: true, container: container("app", gpus("nvidia.com/gpu", 1)), wantAllowed: true, wantReason: ReasonWithinLimit},
+		{
+			name:        "no limits",
+			require:     true,
+			container:   requestsOnly,
+			wantReason:  ReasonGPULimitsMissing,
+			wantMessage: "container app requests 1 nvidia.com/gpu but sets no resource limits; set limits for nvidia.com/gpu equal to its request, and for cpu and memory, so the pod gets the Guaranteed QoS class",
+		},
+		{
+			name:        "no limits without the check",
+			container:   requestsOnly,
+			wantReason:  ReasonLimitMismatch,
+			wantMessage: "container app requests 1 nvidia.com/gpu but sets no limit; GPU limits must equal requests",
+		},
+		{
+			name:        "GPU limit missing",
+			require:     true,
+			container:   cpuLimitOnly,
+			wantReason:  ReasonLimitMismatch,
+			wantMessage: "container app requests 1 nvidia.com/gpu but sets no limit; GPU limits must equal requests",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 1, MaxGPUsPerNamespace: -1, RequireGPULimits: tt.require},
+				testNamespace("default", nil))
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{tt.container}}}
+			decision := evaluator.evaluate(&pod, "default")
+			if decision.Allowed != tt.wantAllowed || decision.Reason != tt.wantReason || decision.Message != tt.wantMessage {
+				t.Errorf("got allowed=%v reason=%s message=%q, want allowed=%v reason=%s message=%q",
+					decision.Allowed, decision.Reason, decision.Message, tt.wantAllowed, tt.wantReason, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestEvaluateMinResources(t *testing.T) {
+	withResources := func(requests, limits corev1.ResourceList) corev1.Container {
+		c := corev1.Container{Name: "app", Resources: corev1.ResourceRequirements{Requests: gpus("nvidia.com/gpu", 1), Limits: gpus("nvidia.com/gpu", 1)}}
+		maps.Copy(c.Resources.Requests, requests)
+		maps.Copy(c.Resources.Limits, limits)
+		return c
+	}
+	enough := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("8Gi")}
+	tests := []struct {
+		name        string
+		container   corev1.Container
+		wantAllowed bool
+		wantMessage string
+	}{
+		{name: "at the floor", container: withResources(enough, nil), wantAllowed: true},
+		{name: "limits only", container: withResources(nil, enough), wantAllowed: true},
+		{
+			name:        "below the floor",
+			container:   withResources(corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("8Gi")}, nil),
+			wantMessage: "container app requests nvidia.com/gpu with 500m cpu; containers requesting GPUs must request at least 2 cpu so they do not starve the GPU",
+		},
+		{
+			name:        "request below the floor despite the limit",
+			container:   withResources(corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("1Gi")}, corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("16Gi")}),
+			wantMessage: "container app requests nvidia.com/gpu with 1Gi memory; containers requesting GPUs must request at least 8Gi memory so they do not starve the GPU",
+		},
+		{
+			name:        "nothing requested",
+			container:   container("app", gpus("nvidia.com/gpu", 1)),
+			wantMessage: "container app requests nvidia.com/gpu with no cpu and no memory; containers requesting GPUs must request at least 2 cpu and 8Gi memory so they do not starve the GPU",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu, memory := resource.MustParse("2"), resource.MustParse("8Gi")
+			evaluator := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 1, MaxGPUsPerNamespace: -1, MinGPUContainerCPU: &cpu, MinGPUContainerMemory: &memory},
+				testNamespace("default", nil))
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "proxy"}, tt.container}}}
+			decision := evaluator.evaluate(&pod, "default")
+			if decision.Allowed != tt.wantAllowed || decision.Message != tt.wantMessage {
+				t.Errorf("got allowed=%v message=%q, want allowed=%v message=%q", decision.Allowed, decision.Message, tt.wantAllowed, tt.wantMessage)
+			}
+			if !tt.wantAllowed && decision.Reason != ReasonMinResourcesNotMet {
+				t.Errorf("reason = %s, want %s", decision.Reason, ReasonMinResourcesNotMet)
+			}
+		})
+	}
+}
+
+func TestEvaluateZeroQuantityGPU(t *testing.T) {
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 0))}}}
+
+	lenient := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: -1, MaxGPUsPerNamespace: -1}, testNamespace("default", nil))
+	if decision := lenient.evaluate(&pod, "default"); !decision.Allowed || decision.Reason != ReasonNoGPU {
+		t.Errorf("got allowed=%v reason=%s, want allowed with %s", decision.Allowed, decision.Reason, ReasonNoGPU)
+	}
+
+	strict := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: -1, MaxGPUsPerNamespace: -1, StrictZeroGPURequests: true}, testNamespace("default", nil))
+	if decision := strict.evaluate(&pod, "default"); decision.Allowed {
+		t.Errorf("strict: expected pod requesting nvidia.com/gpu: 0 to be denied")
+	}
+}
+
+func TestEvaluateNamespaceGPUPrefixes(t *testing.T) {
+	evaluator := newTestEvaluator(Policy{
+		GPUPrefixes:         []string{"nvidia.com"},
+		MaxGPUsPerPod:       1,
+		MaxGPUsPerNamespace: -1,
+		Namespaces: map[string]NamespacePolicy{
+			"rocm":  {GPUPrefixes: []string{"amd.com"}},
+			"mixed": {ExtraGPUPrefixes: []string{"amd.com"}},
+		},
+	}, testNamespace("rocm", nil), testNamespace("mixed", nil), testNamespace("cuda", nil))
+	evaluator.policy.Prepare()
+	nvidia := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 2))}}}
+	amd := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("amd.com/gpu", 2))}}}
+
+	tests := []struct {
+		namespace   string
+		pod         *corev1.Pod
+		wantAllowed bool
+	}{
+		{namespace: "cuda", pod: &nvidia, wantAllowed: false},
+		{namespace: "cuda", pod: &amd, wantAllowed: true},
+		{namespace: "rocm", pod: &nvidia, wantAllowed: true},
+		{namespace: "rocm", pod: &amd, wantAllowed: false},
+		{namespace: "mixed", pod: &nvidia, wantAllowed: false},
+		{namespace: "mixed", pod: &amd, wantAllowed: false},
+	}
+	for _, tt := range tests {
+		requests := tt.pod.Spec.Containers[0].Resources.Requests
+		if decision := evaluator.evaluate(tt.pod, tt.namespace); decision.Allowed != tt.wantAllowed {
+			t.Errorf("%s with %v: allowed = %v, want %v", tt.namespace, requests, decision.Allowed, tt.wantAllowed)
+		}
+	}
+}
+
+func TestEvaluateDisabledAnnotation(t *testing.T) {
+	disabled := testNamespace("incident", nil)
+	disabled.Annotations = map[string]string{DisabledAnnotation: "true"}
+	evaluator := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 0, MaxGPUsPerNamespace: -1},
+		disabled, testNamespace("team", nil))
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 8))}}}
+
+	if decision := evaluator.evaluate(&pod, "incident"); !decision.Allowed || decision.Reason != ReasonEnforcementDisabled {
+		t.Errorf("incident: got allowed=%v reason=%s, want allowed with %s", decision.Allowed, decision.Reason, ReasonEnforcementDisabled)
+	}
+	if decision := evaluator.evaluate(&pod, "team"); decision.Allowed {
+		t.Errorf("team: expected pod to be denied")
+	}
+}
+
+func TestEvaluateMaxGPUsPerPod(t *testing.T) {
+	evaluator := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 2, MaxGPUsPerNamespace: -1}, testNamespace("default", nil))
+
+	tests := []struct {
+		name        string
+		pod         corev1.Pod
+		wantAllowed bool
+	}{
+		{
+			name:        "within limit",
+			pod:         corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 2))}}},
+			wantAllowed: true,
+		},
+		{
+			name: "summed across containers",
+			pod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				container("a", gpus("nvidia.com/gpu", 2)),
+				container("b", gpus("nvidia.com/gpu", 1)),
+			}}},
+		},
+		{
+			name: "init containers are not added to regular containers",
+			pod: corev1.Pod{Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{container("init", gpus("nvidia.com/gpu", 2))},
+				Containers:     []corev1.Container{container("app", gpus("nvidia.com/gpu", 2))},
+			}},
+			wantAllowed: true,
+		},
+		{
+			name: "sequential init containers are not summed",
+			pod: corev1.Pod{Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{
+					container("download", gpus("nvidia.com/gpu", 2)),
+					container("convert", gpus("nvidia.com/gpu", 2)),
+				},
+				Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 1))},
+			}},
+			wantAllowed: true,
+		},
+		{
+			name: "sidecars are added to regular containers",
+			pod: corev1.Pod{Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{sidecar("proxy", gpus("nvidia.com/gpu", 1))},
+				Containers:     []corev1.Container{container("app", gpus("nvidia.com/gpu", 2))},
+			}},
+		},
+		{
+			name: "sidecars are added to later init containers",
+			pod: corev1.Pod{Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{
+					sidecar("proxy", gpus("nvidia.com/gpu", 1)),
+					container("warmup", gpus("nvidia.com/gpu", 2)),
+				},
+			}},
+		},
+		{
+			name: "limits only",
+			pod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "app", Resources: corev1.ResourceRequirements{Limits: gpus("nvidia.com/gpu", 3)}},
+			}}},
+		},
+		{
+			name: "limits only within limit",
+			pod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "app", Resources: corev1.ResourceRequirements{Limits: gpus("nvidia.com/gpu", 2)}},
+			}}},
+			wantAllowed: true,
+		},
+		{
+			name: "pod-level requests",
+			pod: corev1.Pod{Spec: corev1.PodSpec{
+				Resources:  &corev1.ResourceRequirements{Requests: gpus("nvidia.com/gpu", 3)},
+				Containers: []corev1.Container{{Name: "app"}},
+			}},
+		},
+		{
+			name: "pod-level requests covering containers",
+			pod: corev1.Pod{Spec: corev1.PodSpec{
+				Resources:  &corev1.ResourceRequirements{Requests: gpus("nvidia.com/gpu", 2)},
+				Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 1))},
+			}},
+			wantAllowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := evaluator.evaluate(&tt.pod, "default")
+			if decision.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v", decision.Allowed, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestEvaluateMaxGPUsPerContainer(t *testing.T) {
+	limit := int64(2)
+	evaluator := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 8, MaxGPUsPerContainer: &limit, MaxGPUsPerNamespace: -1},
+		testNamespace("default", nil))
+
+	spread := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		container("a", gpus("nvidia.com/gpu", 2)),
+		container("b", gpus("nvidia.com/gpu", 2)),
+	}}}
+	if decision := evaluator.evaluate(&spread, "default"); !decision.Allowed {
+		t.Errorf("spread pod: expected allowed, got %s: %s", decision.Reason, decision.Message)
+	}
+
+	concentrated := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		container("a", gpus("nvidia.com/gpu", 1)),
+		container("trainer", gpus("nvidia.com/gpu", 3)),
+	}}}
+	decision := evaluator.evaluate(&concentrated, "default")
+	if decision.Allowed || decision.Reason != ReasonMaxGPUsPerContainerExceeded {
+		t.Fatalf("concentrated pod: got allowed=%v reason=%s, want denied with %s", decision.Allowed, decision.Reason, ReasonMaxGPUsPerContainerExceeded)
+	}
+	if want := "container trainer requests 3 GPUs, exceeding the limit of 2 per container"; decision.Message != want {
+		t.Errorf("message = %q, want %q", decision.Message, want)
+	}
+	if len(decision.Causes) != 1 || decision.Causes[0].Field != "spec.containers[1].resources.requests[nvidia.com/gpu]" {
+		t.Errorf("causes = %+v, want the trainer container's GPU request", decision.Causes)
+	}
+}
+
+func TestEvaluateLimitCauses(t *testing.T) {
+	evaluator := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 2, MaxMIGDevicesPerPod: -1, MaxGPUsPerNamespace: -1}, testNamespace("default", nil))
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 3))}}}
+
+	decision := evaluator.evaluate(&pod, "default")
+	want := []metav1.StatusCause{{
+		Type:    metav1.CauseTypeFieldValueInvalid,
+		Field:   "spec.containers[*].resources.requests[nvidia.com/gpu]",
+		Message: "must be less than or equal to 2",
+	}}
+	if !reflect.DeepEqual(decision.Causes, want) {
+		t.Errorf("Causes = %+v, want %+v", decision.Causes, want)
+	}
+}
+
+func TestEvaluateMIG(t *testing.T) {
+	evaluator := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 1, MaxMIGDevicesPerPod: 4, MaxGPUsPerNamespace: -1}, testNamespace("default", nil))
+
+	tests := []struct {
+		name        string
+		pod         corev1.Pod
+		wantAllowed bool
+		wantReason  string
+	}{
+		{
+			name:        "MIG slices do not count against the GPU limit",
+			pod:         corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/mig-1g.5gb", 3))}}},
+			wantAllowed: true,
+			wantReason:  ReasonWithinLimit,
+		},
+		{
+			name:       "MIG slices over their own limit",
+			pod:        corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/mig-1g.5gb", 5))}}},
+			wantReason: ReasonMaxMIGExceeded,
+		},
+		{
+			name: "full GPUs over the GPU limit",
+			pod: corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+				container("a", gpus("nvidia.com/gpu", 2)),
+				container("b", gpus("nvidia.com/mig-1g.5gb", 1)),
+			}}},
+			wantReason: ReasonMaxGPUsExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := evaluator.evaluate(&tt.pod, "default")
+			if decision.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v", decision.Allowed, tt.wantAllowed)
+			}
+			if decision.Reason != tt.wantReason {
+				t.Errorf("Reason = %q, want %q", decision.Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestEvaluateResourceQuota(t *testing.T) {
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "gpu-quota", Namespace: "ml"},
+		Status: corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("4")},
+			Used: corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("3")},
+		},
+	}
+	evaluator := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 8, MaxMIGDevicesPerPod: -1, MaxGPUsPerNamespace: -1}, quota)
+	evaluator.CheckResourceQuota = true
+
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 2))}}}
+	decision := evaluator.evaluate(&pod, "ml")
+	if decision.Allowed || decision.Reason != ReasonResourceQuotaExceeded {
+		t.Fatalf("Allowed = %v, Reason = %q, want denial for %q", decision.Allowed, decision.Reason, ReasonResourceQuotaExceeded)
+	}
+	want := "pod requests 2 nvidia.com/gpu but ResourceQuota gpu-quota in namespace ml only has 1 remaining (3 of 4 used)"
+	if decision.Message != want {
+		t.Errorf("message = %q, want %q", decision.Message, want)
+	}
+}
+
+func TestEvaluateDenyMessageTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			name:     "template",
+			template: "{{.PodName}} may not use {{.Resource}} in {{.Namespace}}, contact #gpu-help",
+			want:     "train may not use nvidia.com/gpu in default, contact #gpu-help",
+		},
+		{
+			name:     "invalid template falls back",
+			template: "{{.PodName",
+			want:     "GPU resource nvidia.com/gpu is not allowed in namespace default",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: -1, MaxMIGDevicesPerPod: -1, MaxGPUsPerNamespace: -1, DenyMessageTemplate: tt.template}
+			policy.Prepare()
+			evaluator := newTestEvaluator(policy, testNamespace("default", nil))
+
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "train"},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 1))}},
+			}
+			decision := evaluator.evaluate(&pod, "default")
+			if decision.Allowed {
+				t.Fatal("expected pod to be denied")
+			}
+			if decision.Message != tt.want {
+				t.Errorf("message = %q, want %q", decision.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateMultipleGPUResources(t *testing.T) {
+	requests := gpus("nvidia.com/mig-1g.5gb", 2)
+	requests["nvidia.com/gpu"] = resource.MustParse("3")
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", requests)}}}
+
+	tests := []struct {
+		name       string
+		policy     Policy
+		want       string
+		wantFields []string
+	}{
+		{
+			name:   "over limit",
+			policy: Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 4, MaxMIGDevicesPerPod: -1, MaxGPUsPerNamespace: -1},
+			want:   "pod requests 5 GPUs, exceeding the limit of 4 per pod in namespace default (requested as nvidia.com/gpu, nvidia.com/mig-1g.5gb)",
+			wantFields: []string{
+				"spec.containers[*].resources.requests[nvidia.com/gpu]",
+				"spec.containers[*].resources.requests[nvidia.com/mig-1g.5gb]",
+			},
+		},
+		{
+			name:   "not allowed",
+			policy: Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: -1, MaxMIGDevicesPerPod: -1, MaxGPUsPerNamespace: -1},
+			want:   "GPU resources nvidia.com/gpu, nvidia.com/mig-1g.5gb are not allowed in namespace default",
+		},
+		{
+			name:   "template",
+			policy: Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: -1, MaxMIGDevicesPerPod: -1, MaxGPUsPerNamespace: -1, DenyMessageTemplate: "{{.Resource}} ({{len .Resources}} resources) are not allowed"},
+			want:   "nvidia.com/gpu, nvidia.com/mig-1g.5gb (2 resources) are not allowed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.policy.Prepare()
+			evaluator := newTestEvaluator(tt.policy, testNamespace("default", nil))
+			decision := evaluator.evaluate(&pod, "default")
+			if decision.Allowed {
+				t.Fatal("expected pod to be denied")
+			}
+			if decision.Message != tt.want {
+				t.Errorf("message = %q, want %q", decision.Message, tt.want)
+			}
+			var fields []string
+			for _, cause := range decision.Causes {
+				fields = append(fields, cause.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("cause fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestPodDisplayName(t *testing.T) {
+	controller := true
+	tests := []struct {
+		meta metav1.ObjectMeta
+		want string
+	}{
+		{meta: metav1.ObjectMeta{Name: "train", GenerateName: "train-"}, want: "train"},
+		{meta: metav1.ObjectMeta{GenerateName: "train-7f9c-"}, want: "train-7f9c-*"},
+		{meta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{Kind: "Job", Name: "train", Controller: &controller}}}, want: "<pod of Job/train>"},
+		{want: "<unnamed pod>"},
+	}
+	for _, tt := range tests {
+		if got := PodDisplayName(&corev1.Pod{ObjectMeta: tt.meta}); got != tt.want {
+			t.Errorf("PodDisplayName(%+v) = %q, want %q", tt.meta, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateOwnerKind(t *testing.T) {
+	evaluator := newTestEvaluator(Policy{
+		GPUPrefixes:              []string{"nvidia.com"},
+		MaxGPUsPerPod:            4,
+		MaxGPUsPerPodByOwnerKind: map[string]int64{OwnerKindStandalone: 0},
+		MaxMIGDevicesPerPod:      -1,
+		MaxGPUsPerNamespace:      -1,
+	}, testNamespace("default", nil))
+
+	controller := true
+	owned := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName:    "train-",
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Job", Name: "train", Controller: &controller}},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 2))}},
+	}
+	if decision := evaluator.evaluate(&owned, "default"); !decision.Allowed {
+		t.Errorf("expected Job-owned pod to be allowed, got %q", decision.Message)
+	}
+
+	bare := corev1.Pod{Spec: owned.Spec}
+	if decision := evaluator.evaluate(&bare, "default"); decision.Allowed {
+		t.Error("expected standalone pod to be denied")
+	}
+}
+
+func TestEvaluatePodSelector(t *testing.T) {
+	policy := Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: -1, MaxGPUsPerNamespace: -1, PodSelector: "team in (ml, research)", Mode: ModeEnforce}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	policy.Prepare()
+	evaluator := newTestEvaluator(policy, testNamespace("default", nil))
+
+	tests := []struct {
+		labels      map[string]string
+		wantAllowed bool
+		wantReason  string
+	}{
+		{labels: map[string]string{"team": "ml"}, wantReason: ReasonGPUNotAllowed},
+		{labels: map[string]string{"team": "web"}, wantAllowed: true, wantReason: ReasonPodNotSelected},
+		{labels: nil, wantAllowed: true, wantReason: ReasonPodNotSelected},
+	}
+	for _, tt := range tests {
+		pod := corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Labels: tt.labels},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 1))}},
+		}
+		decision := evaluator.evaluate(&pod, "default")
+		if decision.Allowed != tt.wantAllowed || decision.Reason != tt.wantReason {
+			t.Errorf("labels %v: got allowed=%v reason=%s, want allowed=%v reason=%s", tt.labels, decision.Allowed, decision.Reason, tt.wantAllowed, tt.wantReason)
+		}
+	}
+}
+
+func TestEvaluateMaxGPUsAnnotation(t *testing.T) {
+	evaluator := newTestEvaluator(Policy{
+		GPUPrefixes:              []string{"nvidia.com"},
+		MaxGPUsPerPod:            1,
+		MaxMIGDevicesPerPod:      -1,
+		MaxGPUsPerNamespace:      -1,
+		MaxGPUsAnnotation:        DefaultMaxGPUsAnnotation,
+		MaxGPUsAnnotationCeiling: 4,
+		Namespaces: map[string]NamespacePolicy{
+			"research":  {AllowMaxGPUsAnnotation: true},
+			"unlimited": {MaxGPUsPerPod: ptr.To[int64](-1)},
+		},
+	}, testNamespace("default", nil), testNamespace("research", nil), testNamespace("unlimited", nil))
+
+	tests := []struct {
+		name        string
+		namespace   string
+		annotation  string
+		count       int64
+		wantAllowed bool
+		wantReason  string
+	}{
+		{name: "no annotation", namespace: "research", count: 2, wantReason: ReasonMaxGPUsExceeded},
+		{name: "raised limit", namespace: "research", annotation: "4", count: 4, wantAllowed: true, wantReason: ReasonWithinLimit},
+		{name: "above raised limit", namespace: "research", annotation: "2", count: 3, wantReason: ReasonMaxGPUsExceeded},
+		{name: "above ceiling", namespace: "research", annotation: "8", count: 1, wantReason: ReasonInvalidMaxGPUsAnnotation},
+		{name: "not an integer", namespace: "research", annotation: "lots", count: 1, wantReason: ReasonInvalidMaxGPUsAnnotation},
+		{name: "raise without opt-in", namespace: "default", annotation: "4", count: 2, wantReason: ReasonMaxGPUsExceeded},
+		{name: "lowered without opt-in", namespace: "unlimited", annotation: "2", count: 3, wantReason: ReasonMaxGPUsExceeded},
+		{name: "unlimited without opt-in", namespace: "unlimited", annotation: "2", count: 2, wantAllowed: true, wantReason: ReasonWithinLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", tt.count))}}}
+			if tt.annotation != "" {
+				pod.Annotations = map[string]string{DefaultMaxGPUsAnnotation: tt.annotation}
+			}
+			decision := evaluator.evaluate(&pod, tt.namespace)
+			if decision.Allowed != tt.wantAllowed || decision.Reason != tt.wantReason {
+				t.Errorf("got allowed=%v reason=%s, want allowed=%v reason=%s", decision.Allowed, decision.Reason, tt.wantAllowed, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestEvaluateResourceQuotaUpdate(t *testing.T) {
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "gpu-quota", Namespace: "ml"},
+		Status: corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("4")},
+			Used: corev1.ResourceList{"requests.nvidia.com/gpu": resource.MustParse("4")},
+		},
+	}
+	existing := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "train", Namespace: "ml"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 2))}},
+	}
+	evaluator := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 8, MaxMIGDevicesPerPod: -1, MaxGPUsPerNamespace: -1}, quota, existing)
+	evaluator.CheckResourceQuota = true
+
+	tests := []struct {
+		name        string
+		podName     string
+		gpus        int64
+		wantAllowed bool
+	}{
+		{name: "update at quota", podName: "train", gpus: 2, wantAllowed: true},
+		{name: "update adding GPUs", podName: "train", gpus: 3},
+		{name: "new pod", podName: "eval", gpus: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: tt.podName, Namespace: "ml"},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", tt.gpus))}},
+			}
+			decision := evaluator.evaluate(&pod, "ml")
+			if decision.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v: %s", decision.Allowed, tt.wantAllowed, decision.Message)
+			}
+		})
+	}
+}
+
+func TestEvaluateExemptionBeforeShapeChecks(t *testing.T) {
+	one := resource.MustParse("1")
+	evaluator := newTestEvaluator(Policy{
+		GPUPrefixes:           []string{"nvidia.com"},
+		MaxGPUsPerPod:         8,
+		MaxMIGDevicesPerPod:   -1,
+		MaxGPUsPerNamespace:   -1,
+		RequireGPULimits:      true,
+		ExemptServiceAccounts: []string{"team/ci"},
+		ExemptPriorityClasses: []string{"critical"},
+	}, testNamespace("team", nil))
+
+	tests := []struct {
+		name       string
+		pod        func(*corev1.Pod)
+		wantReason string
+	}{
+		{name: "not exempt", wantReason: ReasonLimitMismatch},
+		{name: "service account", pod: func(pod *corev1.Pod) { pod.Spec.ServiceAccountName = "ci" }, wantReason: ReasonExemptServiceAccount},
+		{name: "priority class", pod: func(pod *corev1.Pod) { pod.Spec.PriorityClassName = "critical" }, wantReason: ReasonExemptPriority},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := corev1.Container{Name: "app", Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{"nvidia.com/gpu": one},
+				Limits:   corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("2")},
+			}}
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{app}}}
+			if tt.pod != nil {
+				tt.pod(&pod)
+			}
+			decision := evaluator.evaluate(&pod, "team")
+			if decision.Reason != tt.wantReason {
+				t.Errorf("Reason = %q, want %q: %s", decision.Reason, tt.wantReason, decision.Message)
+			}
+		})
+	}
+}
+
+func TestEvaluateNamespaceQuota(t *testing.T) {
+	existing := func(name string, count int64, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ml"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", count))}},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	evaluator := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 8, MaxMIGDevicesPerPod: -1, MaxGPUsPerNamespace: 4, MaxGPUsAnnotationCeiling: -1},
+		testNamespace("ml", nil),
+		existing("train", 2, corev1.PodRunning),
+		existing("eval", 1, corev1.PodPending),
+		existing("done", 4, corev1.PodSucceeded),
+		existing("crashed", 4, corev1.PodFailed),
+	)
+
+	tests := []struct {
+		name        string
+		podName     string
+		count       int64
+		wantAllowed bool
+	}{
+		{name: "reaches the quota", podName: "new", count: 1, wantAllowed: true},
+		{name: "one over the quota", podName: "new", count: 2},
+		{name: "same name is not counted twice", podName: "train", count: 3, wantAllowed: true},
+		{name: "same name over the quota", podName: "train", count: 4},
+		{name: "generated name", count: 1, wantAllowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: tt.podName, Namespace: "ml"},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", tt.count))}},
+			}
+			decision := evaluator.evaluate(&pod, "ml")
+			if decision.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v: %s", decision.Allowed, tt.wantAllowed, decision.Message)
+			}
+			if !tt.wantAllowed && decision.Reason != ReasonNamespaceQuotaExceeded {
+				t.Errorf("Reason = %q, want %q", decision.Reason, ReasonNamespaceQuotaExceeded)
+			}
+		})
+	}
+
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 2))}}}
+	decision := evaluator.evaluate(&pod, "ml")
+	if want := "pod requests 2 GPUs but namespace ml already uses 3 of its 4 GPU quota"; decision.Message != want {
+		t.Errorf("message = %q, want %q", decision.Message, want)
+	}
+}
@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// NamedPolicyPrefix is the path prefix every named policy is served under.
+const NamedPolicyPrefix = "/validate/"
+
+// policyFile is the layout of the --config file: the default policy served on
+// /validate, plus any number of named policies served on their own paths.
+type policyFile struct {
+	Policy
+	Policies []json.RawMessage `json:"policies,omitempty"`
+}
+
+// namedPolicy is one entry of the policies list. Fields it does not set are
+// inherited from the top-level policy in the file.
+type namedPolicy struct {
+	Name string `json:"name"`
+	Path string `json:"path,omitempty"`
+	Policy
+}
+
+// Load reads the YAML policy file at path. Fields missing from the file
+// keep the values from defaults.
+func Load(path string, defaults Policy) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy file: %w", err)
+	}
+	return Parse(data, defaults)
+}
+
+// Parse decodes a YAML policy in the layout of the policy file, wherever it
+// was read from. Fields missing from data keep the values from defaults.
+func Parse(data []byte, defaults Policy) (*Policy, error) {
+	base, err := defaults.clone()
+	if err != nil {
+		return nil, err
+	}
+	file := policyFile{Policy: base}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("parse policy: %w", err)
+	}
+	policy := file.Policy
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	policy.Prepare()
+
+	names := make(map[string]bool, len(file.Policies))
+	for i, raw := range file.Policies {
+		named, err := loadNamedPolicy(raw, policy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policies[%d]: %w", i, err)
+		}
+		if names[named.name] {
+			return nil, fmt.Errorf("invalid policies[%d]: duplicate name %q", i, named.name)
+		}
+		names[named.name] = true
+		if policy.routes == nil {
+			policy.routes = make(map[string]*Policy)
+		}
+		if _, ok := policy.routes[named.path]; ok {
+			return nil, fmt.Errorf("invalid policies[%d]: duplicate path %q", i, named.path)
+		}
+		policy.routes[named.path] = named
+	}
+	return &policy, nil
+}
+
+// loadNamedPolicy decodes a single entry of the policies list on top of base.
+func loadNamedPolicy(raw json.RawMessage, base Policy) (*Policy, error) {
+	policy, err := base.clone()
+	if err != nil {
+		return nil, err
+	}
+	named := namedPolicy{Policy: policy}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&named); err != nil {
+		return nil, err
+	}
+	if named.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if named.Path == "" {
+		named.Path = NamedPolicyPrefix + named.Name
+	}
+	if !strings.HasPrefix(named.Path, NamedPolicyPrefix) || len(named.Path) == len(NamedPolicyPrefix) {
+		return nil, fmt.Errorf("path %q must be below %s", named.Path, NamedPolicyPrefix)
+	}
+	if err := named.Policy.Validate(); err != nil {
+		return nil, fmt.Errorf("policy %s: %w", named.Name, err)
+	}
+	named.Policy.Prepare()
+	named.Policy.name = named.Name
+	named.Policy.path = named.Path
+	return &named.Policy, nil
+}
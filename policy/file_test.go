@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const namedPoliciesConfig = `
+maxGPUsPerPod: 1
+namespaces:
+  ml:
+    maxGPUsPerPod: 2
+policies:
+- name: team-a
+  maxGPUsPerPod: 4
+- name: team-b
+  path: /validate/batch
+`
+
+func writePolicyFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPolicyNamedPolicies(t *testing.T) {
+	defaults := Policy{GPUPrefixes: []string{"nvidia.com"}, MaxMIGDevicesPerPod: -1, MaxGPUsPerNamespace: -1, Mode: ModeEnforce}
+	policy, err := Load(writePolicyFile(t, namedPoliciesConfig), defaults)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	teamA := policy.ForPath("/validate/team-a")
+	if teamA == nil || teamA.MaxGPUsPerPod != 4 {
+		t.Fatalf("team-a policy = %+v, want maxGPUsPerPod 4", teamA)
+	}
+	teamB := policy.ForPath("/validate/batch")
+	if teamB == nil || teamB.MaxGPUsPerPod != 1 {
+		t.Fatalf("team-b policy = %+v, want maxGPUsPerPod inherited as 1", teamB)
+	}
+	if limit := teamB.Namespaces["ml"].MaxGPUsPerPod; limit == nil || *limit != 2 {
+		t.Errorf("team-b did not inherit the ml namespace override")
+	}
+	if policy.ForPath("/validate/team-b") != nil {
+		t.Error("team-b should only be served on its explicit path")
+	}
+}
+
+func TestLoadPolicyNamedPoliciesInvalid(t *testing.T) {
+	defaults := Policy{GPUPrefixes: []string{"nvidia.com"}, MaxMIGDevicesPerPod: -1, MaxGPUsPerNamespace: -1, Mode: ModeEnforce}
+	for name, config := range map[string]string{
+		"missing name":   "policies:\n- maxGPUsPerPod: 2\n",
+		"duplicate name": "policies:\n- name: a\n- name: a\n  path: /validate/other\n",
+		"duplicate path": "policies:\n- name: a\n- name: b\n  path: /validate/a\n",
+		"path outside":   "policies:\n- name: a\n  path: /mutate/a\n",
+		"nested":         "policies:\n- name: a\n  policies: []\n",
+		"invalid limit":  "policies:\n- name: a\n  maxGPUsPerPod: -2\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writePolicyFile(t, config), defaults); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package policy
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// isFractionalGPUResource reports whether the resource expresses a share of a
+// GPU rather than a device count. Like time-sliced resources, it must also be
+// a GPU resource.
+func (p *Policy) isFractionalGPUResource(resourceName corev1.ResourceName) bool {
+	_, ok := p.FractionalGPUResources[string(resourceName)]
+	return ok && p.IsGPUResource(resourceName)
+}
+
+// podFractionalMilliGPUs returns the fractional GPU resources requested by the
+// pod in thousandths of a GPU, using the same init/regular container semantics
+// as podRequests. Shares are rounded up, so a pod never gets more than the
+// limit through rounding.
+func (p *Policy) podFractionalMilliGPUs(pod *corev1.Pod) int64 {
+	return podTotal(pod, func(requests corev1.ResourceList) int64 {
+		var total int64
+		for resourceName, quantity := range requests {
+			if p.isFractionalGPUResource(resourceName) {
+				denominator := p.FractionalGPUResources[string(resourceName)]
+				total += (quantity.MilliValue() + denominator - 1) / denominator
+			}
+		}
+		return total
+	})
+}
+
+// formatMilliGPUs formats thousandths of a GPU as a decimal number of GPUs.
+func formatMilliGPUs(milli int64) string {
+	return strconv.FormatFloat(float64(milli)/1000, 'f', -1, 64)
+}
@@ -0,0 +1,66 @@
+package policy
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestEvaluateFractionalGPUs(t *testing.T) {
+	limit := resource.MustParse("1.5")
+	policy := Policy{
+		GPUPrefixes:            []string{"nvidia.com", "tencent.com"},
+		MaxGPUsPerPod:          1,
+		MaxGPUsPerNamespace:    -1,
+		MaxMIGDevicesPerPod:    -1,
+		FractionalGPUResources: map[string]int64{"tencent.com/vcuda-core": 100, "example.com/gpu-share": 100},
+		MaxFractionalGPUs:      &limit,
+		Mode:                   ModeEnforce,
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	evaluator := newTestEvaluator(policy, testNamespace("default", nil))
+	vcuda := func(cores int64) corev1.ResourceList { return gpus("tencent.com/vcuda-core", cores) }
+
+	tests := []struct {
+		name        string
+		pod         corev1.PodSpec
+		wantAllowed bool
+		wantMessage string
+	}{
+		{name: "half a GPU", pod: corev1.PodSpec{Containers: []corev1.Container{container("app", vcuda(50))}}, wantAllowed: true},
+		{
+			name:        "shares are summed",
+			pod:         corev1.PodSpec{Containers: []corev1.Container{container("a", vcuda(100)), container("b", vcuda(75))}},
+			wantMessage: "pod requests 1.75 GPUs through fractional GPU resources, exceeding the limit of 1.5 per pod",
+		},
+		{
+			name:        "init containers run first",
+			pod:         corev1.PodSpec{InitContainers: []corev1.Container{container("init", vcuda(150))}, Containers: []corev1.Container{container("app", vcuda(50))}},
+			wantAllowed: true,
+		},
+		{
+			name:        "not counted as whole GPUs",
+			pod:         corev1.PodSpec{Containers: []corev1.Container{container("a", vcuda(100)), container("b", gpus("nvidia.com/gpu", 1))}},
+			wantAllowed: true,
+		},
+		{
+			name:        "resource outside the GPU prefixes ignored",
+			pod:         corev1.PodSpec{Containers: []corev1.Container{container("a", vcuda(100)), container("b", gpus("example.com/gpu-share", 200))}},
+			wantAllowed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := evaluator.evaluate(&corev1.Pod{Spec: tt.pod}, "default")
+			if decision.Allowed != tt.wantAllowed || decision.Message != tt.wantMessage {
+				t.Errorf("got allowed=%v message=%q, want allowed=%v message=%q", decision.Allowed, decision.Message, tt.wantAllowed, tt.wantMessage)
+			}
+			if !tt.wantAllowed && decision.Reason != ReasonMaxFractionalGPUsExceeded {
+				t.Errorf("reason = %s, want %s", decision.Reason, ReasonMaxFractionalGPUsExceeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/distribution/reference"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// imageRegistry returns the registry host of an image reference, normalizing
+// implicit Docker Hub references such as "ubuntu" or "library/ubuntu:22.04" to
+// docker.io. Tags and digests are accepted and ignored.
+func imageRegistry(image string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return "", err
+	}
+	return reference.Domain(named), nil
+}
+
+// checkGPUImageRegistries verifies that every container requesting a GPU uses
+// an image from an allowed registry. It returns an error describing the first
+// violation.
+func (p *Policy) checkGPUImageRegistries(pod *corev1.Pod) error {
+	if len(p.AllowedGPUImageRegistries) == 0 {
+		return nil
+	}
+	for _, container := range allContainers(pod) {
+		if _, ok := findResourceIn(EffectiveRequests(container.Resources), p.IsGPUResource); !ok {
+			continue
+		}
+		registry, err := imageRegistry(container.Image)
+		if err != nil {
+			return fmt.Errorf("container %s requests a GPU but its image %q cannot be parsed: %v", container.Name, container.Image, err)
+		}
+		if !contains(p.AllowedGPUImageRegistries, registry) {
+			return fmt.Errorf("container %s requests a GPU but its image %s is from registry %s; GPU workloads must use images from %v", container.Name, container.Image, registry, p.AllowedGPUImageRegistries)
+		}
+	}
+	return nil
+}
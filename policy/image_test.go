@@ -0,0 +1,28 @@
+package policy
+
+import "testing"
+
+func TestImageRegistry(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{image: "ubuntu", want: "docker.io"},
+		{image: "library/ubuntu:22.04", want: "docker.io"},
+		{image: "nvcr.io/nvidia/pytorch:24.01-py3", want: "nvcr.io"},
+		{image: "registry.example.com:5000/ml/train@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", want: "registry.example.com:5000"},
+		{image: "localhost/train:latest", want: "localhost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			got, err := imageRegistry(tt.image)
+			if err != nil {
+				t.Fatalf("imageRegistry(%q) returned error: %v", tt.image, err)
+			}
+			if got != tt.want {
+				t.Errorf("imageRegistry(%q) = %q, want %q", tt.image, got, tt.want)
+			}
+		})
+	}
+}
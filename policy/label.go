@@ -0,0 +1,37 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// compileRequiredLabelPattern compiles RequiredGPULabelPattern, anchored so
+// that it must match the whole label value.
+func (p *Policy) compileRequiredLabelPattern() (*regexp.Regexp, error) {
+	if p.RequiredGPULabelPattern == "" {
+		return nil, nil
+	}
+	pattern, err := regexp.Compile("^(?:" + p.RequiredGPULabelPattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid required GPU label pattern %q: %w", p.RequiredGPULabelPattern, err)
+	}
+	return pattern, nil
+}
+
+// checkRequiredLabel verifies that a GPU pod carries RequiredGPULabel, e.g. a
+// cost center for chargeback, with a value matching RequiredGPULabelPattern.
+func (p *Policy) checkRequiredLabel(pod *corev1.Pod) error {
+	if p.RequiredGPULabel == "" {
+		return nil
+	}
+	value, ok := pod.Labels[p.RequiredGPULabel]
+	if !ok || value == "" {
+		return fmt.Errorf("GPU pods must carry the %s label", p.RequiredGPULabel)
+	}
+	if p.requiredLabelPattern != nil && !p.requiredLabelPattern.MatchString(value) {
+		return fmt.Errorf("label %s=%s of GPU pod does not match %s", p.RequiredGPULabel, value, p.RequiredGPULabelPattern)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// applyLimitRanges returns a copy of the pod with the container defaults of
+// the namespace's LimitRanges applied, as the LimitRanger admission plugin
+// does, so that a GPU limit injected by a LimitRange is evaluated. Like the
+// plugin, it skips ephemeral containers, which may not set resources. ok is false
+// if the LimitRanges could not be listed, and decision then holds the outcome.
+func (e *Evaluator) applyLimitRanges(ctx context.Context, policy *Policy, pod *corev1.Pod, namespace string) (defaulted *corev1.Pod, decision Decision, ok bool) {
+	if !e.ApplyLimitRangeDefaults || policy.IsSkippedNamespace(namespace) {
+		return pod, Decision{}, true
+	}
+	limitRanges, err := e.Cluster.ListLimitRanges(ctx, namespace)
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Failed to list limit ranges", "namespace", namespace)
+		if e.FailOpen {
+			return pod, allow(ReasonLimitRangeLookupFailed), false
+		}
+		return pod, deny(ReasonLimitRangeLookupFailed, fmt.Sprintf("unable to apply limit range defaults for namespace %s: %v", namespace, err)), false
+	}
+	if len(limitRanges) == 0 {
+		return pod, Decision{}, true
+	}
+	pod = pod.DeepCopy()
+	for _, limitRange := range limitRanges {
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type != corev1.LimitTypeContainer {
+				continue
+			}
+			for i := range pod.Spec.Containers {
+				applyContainerDefaults(&pod.Spec.Containers[i].Resources, item)
+			}
+			for i := range pod.Spec.InitContainers {
+				applyContainerDefaults(&pod.Spec.InitContainers[i].Resources, item)
+			}
+		}
+	}
+	return pod, Decision{}, true
+}
+
+// applyContainerDefaults fills in the limits and requests the container does
+// not set from the LimitRange item. Values the container sets are kept.
+func applyContainerDefaults(resources *corev1.ResourceRequirements, item corev1.LimitRangeItem) {
+	for resourceName, limit := range item.Default {
+		if _, ok := resources.Limits[resourceName]; ok {
+			continue
+		}
+		if resources.Limits == nil {
+			resources.Limits = make(corev1.ResourceList, len(item.Default))
+		}
+		resources.Limits[resourceName] = limit.DeepCopy()
+	}
+	for resourceName, request := range item.DefaultRequest {
+		if _, ok := resources.Requests[resourceName]; ok {
+			continue
+		}
+		if resources.Requests == nil {
+			resources.Requests = make(corev1.ResourceList, len(item.DefaultRequest))
+		}
+		resources.Requests[resourceName] = request.DeepCopy()
+	}
+}
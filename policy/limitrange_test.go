@@ -0,0 +1,92 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateLimitRangeDefaults(t *testing.T) {
+	limitRange := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "gpu-defaults", Namespace: "ml"},
+		Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{
+			{Type: corev1.LimitTypePod, Max: gpus("nvidia.com/gpu", 8)},
+			{Type: corev1.LimitTypeContainer, Default: gpus("nvidia.com/gpu", 2), DefaultRequest: gpus("nvidia.com/gpu", 2)},
+		}},
+	}
+	tests := []struct {
+		name        string
+		apply       bool
+		namespace   string
+		resources   corev1.ResourceList
+		wantAllowed bool
+		wantReason  string
+	}{
+		{name: "disabled", namespace: "ml", wantAllowed: true, wantReason: ReasonNoGPU},
+		{name: "default applied", apply: true, namespace: "ml", wantReason: ReasonMaxGPUsExceeded},
+		{name: "explicit request kept", apply: true, namespace: "ml", resources: gpus("nvidia.com/gpu", 1), wantAllowed: true, wantReason: ReasonWithinLimit},
+		{name: "no limit range", apply: true, namespace: "team", wantAllowed: true, wantReason: ReasonNoGPU},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 1, MaxGPUsPerNamespace: -1, MaxGPUsAnnotationCeiling: -1},
+				testNamespace("ml", nil), testNamespace("team", nil), limitRange)
+			evaluator.ApplyLimitRangeDefaults = tt.apply
+
+			pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", tt.resources)}}}
+			decision := evaluator.evaluate(&pod, tt.namespace)
+			if decision.Allowed != tt.wantAllowed || decision.Reason != tt.wantReason {
+				t.Errorf("got allowed=%v reason=%s, want allowed=%v reason=%s (%s)", decision.Allowed, decision.Reason, tt.wantAllowed, tt.wantReason, decision.Message)
+			}
+			if len(pod.Spec.Containers[0].Resources.Limits) != len(tt.resources) {
+				t.Errorf("evaluation modified the pod: %v", pod.Spec.Containers[0].Resources)
+			}
+		})
+	}
+}
+
+func TestApplyContainerDefaults(t *testing.T) {
+	resources := corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}}
+	applyContainerDefaults(&resources, corev1.LimitRangeItem{
+		Default:        corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi"), corev1.ResourceCPU: resource.MustParse("2")},
+		DefaultRequest: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+	})
+	if got := resources.Limits[corev1.ResourceMemory]; got.String() != "1Gi" {
+		t.Errorf("memory limit = %s, want the container's own 1Gi", got.String())
+	}
+	if got := resources.Limits[corev1.ResourceCPU]; got.String() != "2" {
+		t.Errorf("cpu limit = %s, want the default 2", got.String())
+	}
+	if got := resources.Requests[corev1.ResourceCPU]; got.String() != "1" {
+		t.Errorf("cpu request = %s, want the default request 1", got.String())
+	}
+}
+
+func TestApplyLimitRangesSkipsEphemeralContainers(t *testing.T) {
+	limitRange := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "gpu-defaults", Namespace: "ml"},
+		Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{
+			{Type: corev1.LimitTypeContainer, Default: gpus("nvidia.com/gpu", 2), DefaultRequest: gpus("nvidia.com/gpu", 2)},
+		}},
+	}
+	evaluator := newTestEvaluator(Policy{GPUPrefixes: []string{"nvidia.com"}}, testNamespace("ml", nil), limitRange)
+	evaluator.ApplyLimitRangeDefaults = true
+
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		Containers:          []corev1.Container{{Name: "app"}},
+		EphemeralContainers: []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug"}}},
+	}}
+	defaulted, _, ok := evaluator.applyLimitRanges(context.Background(), evaluator.policy, pod, "ml")
+	if !ok {
+		t.Fatal("applyLimitRanges() failed")
+	}
+	if limits := defaulted.Spec.Containers[0].Resources.Limits; len(limits) != 1 {
+		t.Errorf("container limits = %v, want the LimitRange default", limits)
+	}
+	if resources := defaulted.Spec.EphemeralContainers[0].Resources; resources.Limits != nil || resources.Requests != nil {
+		t.Errorf("ephemeral container resources = %+v, want none", resources)
+	}
+}
@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// GPU resource name match modes.
+const (
+	MatchModePrefix = "prefix"
+	MatchModeGlob   = "glob"
+	MatchModeRegex  = "regex"
+)
+
+type resourceMatcher func(corev1.ResourceName) bool
+
+// compileMatchers compiles each GPU pattern according to the match mode. Glob
+// patterns use path.Match syntax, so "*/gpu" matches "nvidia.com/gpu". Regular
+// expressions must match the whole resource name.
+func compileMatchers(mode string, patterns []string) (map[string]resourceMatcher, error) {
+	matchers := make(map[string]resourceMatcher, len(patterns))
+	for _, pattern := range patterns {
+		switch mode {
+		case "", MatchModePrefix:
+			matchers[pattern] = func(name corev1.ResourceName) bool {
+				return strings.HasPrefix(string(name), pattern)
+			}
+		case MatchModeGlob:
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+			}
+			matchers[pattern] = func(name corev1.ResourceName) bool {
+				matched, _ := path.Match(pattern, string(name))
+				return matched
+			}
+		case MatchModeRegex:
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+			}
+			matchers[pattern] = func(name corev1.ResourceName) bool {
+				return re.MatchString(string(name))
+			}
+		default:
+			return nil, fmt.Errorf("invalid GPU match mode %q, must be %s, %s or %s", mode, MatchModePrefix, MatchModeGlob, MatchModeRegex)
+		}
+	}
+	return matchers, nil
+}
+
+// matchesPattern reports whether the resource matches a single GPU pattern.
+// Policies that have not been prepared fall back to prefix matching.
+func (p *Policy) matchesPattern(pattern string, resourceName corev1.ResourceName) bool {
+	if matcher, ok := p.matchers[pattern]; ok {
+		return matcher(resourceName)
+	}
+	return strings.HasPrefix(string(resourceName), pattern)
+}
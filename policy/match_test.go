@@ -0,0 +1,63 @@
+package policy
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestIsGPUResourceMatchModes(t *testing.T) {
+	tests := []struct {
+		mode     string
+		patterns []string
+		resource corev1.ResourceName
+		want     bool
+	}{
+		{mode: MatchModePrefix, patterns: []string{"nvidia.com"}, resource: "nvidia.com/gpu", want: true},
+		{mode: MatchModePrefix, patterns: []string{"nvidia.com"}, resource: "amd.com/gpu", want: false},
+		{mode: MatchModeGlob, patterns: []string{"*/gpu"}, resource: "amd.com/gpu", want: true},
+		{mode: MatchModeGlob, patterns: []string{"*/gpu"}, resource: "nvidia.com/mig-1g.5gb", want: false},
+		{mode: MatchModeRegex, patterns: []string{`.*\.com/gpu`}, resource: "gpu.intel.com/gpu", want: true},
+		{mode: MatchModeRegex, patterns: []string{`.*\.com/gpu`}, resource: "nvidia.com/gpu-memory", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode+"/"+string(tt.resource), func(t *testing.T) {
+			policy := &Policy{GPUPrefixes: tt.patterns, GPUMatchMode: tt.mode}
+			policy.Prepare()
+			if got := policy.IsGPUResource(tt.resource); got != tt.want {
+				t.Errorf("IsGPUResource(%q) = %v, want %v", tt.resource, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileMatchersRejectsInvalidRegex(t *testing.T) {
+	if _, err := compileMatchers(MatchModeRegex, []string{"nvidia.com/(gpu"}); err == nil {
+		t.Error("expected an error for an invalid regex")
+	}
+}
+
+func TestGPUSuffixes(t *testing.T) {
+	policy := &Policy{GPUPrefixes: []string{"example.com"}, GPUSuffixes: []string{"/gpu", "-gpu"}}
+	policy.Prepare()
+	for resource, want := range map[corev1.ResourceName]bool{
+		"example.com/accelerator-gpu": true,
+		"vendor.io/gpu":               true,
+		"example.com/fpga":            true,
+		"vendor.io/gpu-memory":        false,
+	} {
+		if got := policy.IsGPUResource(resource); got != want {
+			t.Errorf("IsGPUResource(%q) = %v, want %v", resource, got, want)
+		}
+	}
+
+	// example.com/accelerator-gpu matches a prefix and a suffix but is one
+	// resource.
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		container("app", gpus("example.com/accelerator-gpu", 2)),
+	}}}
+	if got := policy.PodGPURequests(pod); got != 2 {
+		t.Errorf("PodGPURequests = %d, want 2", got)
+	}
+}
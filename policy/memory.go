@@ -0,0 +1,67 @@
+package policy
+
+import (
+	"math"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// maxGPUMemoryBytes bounds the GPU memory quantities that can be evaluated.
+// Binary quantities beyond it are clamped to it when parsed, so reaching it
+// counts as an overflow.
+var maxGPUMemoryBytes = *resource.NewQuantity(math.MaxInt64, resource.BinarySI)
+
+// isGPUMemoryResource reports whether the resource expresses GPU memory rather
+// than a device count.
+func (p *Policy) isGPUMemoryResource(resourceName corev1.ResourceName) bool {
+	return contains(p.GPUMemoryResources, string(resourceName))
+}
+
+// podGPUMemory returns the effective GPU memory requested by the pod in bytes,
+// summed with podTotal like podRequests, so that sidecars count, and
+// comparable with a limit such as 48Gi regardless of the suffixes used in the
+// pod. Sums saturate at math.MaxInt64, so ok is false when the memory does
+// not fit in an int64.
+func (p *Policy) podGPUMemory(pod *corev1.Pod) (bytes int64, ok bool) {
+	bytes = podTotal(pod, func(requests corev1.ResourceList) int64 {
+		var total int64
+		for resourceName, quantity := range requests {
+			if p.isGPUMemoryResource(resourceName) {
+				memory, _ := p.normalizeGPUMemory(quantity)
+				total = addSaturating(total, memory)
+			}
+		}
+		return total
+	})
+	return bytes, bytes < math.MaxInt64
+}
+
+// normalizeGPUMemory converts a GPU memory quantity into bytes. Plugins that
+// count in units of GPUMemoryUnit (e.g. MiB) request plain integers, which are
+// scaled by it. The API server may write a round count such as 8000 as 8k, so
+// any whole decimal quantity is taken as a count, while quantities with a
+// binary suffix such as 8Gi, or a fraction, are already in bytes. ok is false
+// when the result does not fit in an int64.
+func (p *Policy) normalizeGPUMemory(quantity resource.Quantity) (bytes int64, ok bool) {
+	if quantity.Cmp(maxGPUMemoryBytes) >= 0 {
+		return math.MaxInt64, false
+	}
+	if p.GPUMemoryUnit == nil || !isPlainCount(quantity) {
+		return quantity.Value(), true
+	}
+	count, unit := quantity.Value(), p.GPUMemoryUnit.Value()
+	if unit > 0 && count > math.MaxInt64/unit {
+		return math.MaxInt64, false
+	}
+	return count * unit, true
+}
+
+// isPlainCount reports whether the quantity is a whole number without a
+// binary suffix.
+func isPlainCount(quantity resource.Quantity) bool {
+	if quantity.Format != resource.DecimalSI {
+		return false
+	}
+	return quantity.AsDec().Scale() <= 0
+}
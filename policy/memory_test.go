@@ -0,0 +1,100 @@
+package policy
+
+import (
+	"math"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestPodGPUMemory(t *testing.T) {
+	mib := resource.MustParse("1Mi")
+	policy := &Policy{GPUPrefixes: []string{"nvidia.com"}, GPUMemoryResources: []string{"nvidia.com/gpu-memory"}, GPUMemoryUnit: &mib}
+
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		InitContainers: []corev1.Container{container("init", corev1.ResourceList{"nvidia.com/gpu-memory": resource.MustParse("40960")})},
+		Containers: []corev1.Container{
+			container("a", corev1.ResourceList{"nvidia.com/gpu-memory": resource.MustParse("16384")}),
+			container("b", corev1.ResourceList{"nvidia.com/gpu-memory": resource.MustParse("16Gi")}),
+		},
+	}}
+
+	got, ok := policy.podGPUMemory(pod)
+	if want := int64(40 << 30); got != want || !ok {
+		t.Errorf("podGPUMemory = %d, %v, want %d", got, ok, want)
+	}
+	if total := policy.PodGPURequests(pod); total != 0 {
+		t.Errorf("GPU memory must not count as GPUs, got %d", total)
+	}
+}
+
+func TestPodGPUMemorySidecar(t *testing.T) {
+	mib := resource.MustParse("1Mi")
+	policy := &Policy{GPUPrefixes: []string{"nvidia.com"}, GPUMemoryResources: []string{"nvidia.com/gpu-memory"}, GPUMemoryUnit: &mib}
+
+	always := corev1.ContainerRestartPolicyAlways
+	sidecar := container("sidecar", corev1.ResourceList{"nvidia.com/gpu-memory": resource.MustParse("8Gi")})
+	sidecar.RestartPolicy = &always
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		InitContainers: []corev1.Container{sidecar},
+		Containers:     []corev1.Container{container("app", corev1.ResourceList{"nvidia.com/gpu-memory": resource.MustParse("16Gi")})},
+	}}
+
+	got, ok := policy.podGPUMemory(pod)
+	if want := int64(24 << 30); got != want || !ok {
+		t.Errorf("podGPUMemory = %d, %v, want %d", got, ok, want)
+	}
+}
+
+func TestNormalizeGPUMemory(t *testing.T) {
+	mib := resource.MustParse("1Mi")
+	tests := []struct {
+		name     string
+		unit     *resource.Quantity
+		quantity string
+		want     int64
+		wantOK   bool
+	}{
+		{name: "count", unit: &mib, quantity: "8192", want: 8 << 30, wantOK: true},
+		{name: "count written with a decimal suffix", unit: &mib, quantity: "8k", want: 8000 << 20, wantOK: true},
+		{name: "binary suffix", unit: &mib, quantity: "8Gi", want: 8 << 30, wantOK: true},
+		{name: "no unit", quantity: "8192", want: 8192, wantOK: true},
+		{name: "scaled count overflows", unit: &mib, quantity: "9000000000000", want: math.MaxInt64},
+		{name: "quantity overflows", quantity: "16Ei", want: math.MaxInt64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &Policy{GPUMemoryUnit: tt.unit}
+			got, ok := policy.normalizeGPUMemory(resource.MustParse(tt.quantity))
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("normalizeGPUMemory(%s) = %d, %v, want %d, %v", tt.quantity, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestEvaluateGPUMemoryOverflow(t *testing.T) {
+	mib := resource.MustParse("1Mi")
+	limit := resource.MustParse("48Gi")
+	evaluator := newTestEvaluator(Policy{
+		GPUPrefixes:         []string{"nvidia.com"},
+		GPUMemoryResources:  []string{"nvidia.com/gpu-memory"},
+		GPUMemoryUnit:       &mib,
+		MaxGPUMemory:        &limit,
+		MaxGPUsPerPod:       -1,
+		MaxMIGDevicesPerPod: -1,
+		MaxGPUsPerNamespace: -1,
+	}, testNamespace("default", nil))
+
+	for _, quantities := range [][]string{{"9000000000000"}, {"8Ei", "8Ei"}} {
+		var containers []corev1.Container
+		for _, quantity := range quantities {
+			containers = append(containers, container("app", corev1.ResourceList{"nvidia.com/gpu-memory": resource.MustParse(quantity)}))
+		}
+		decision := evaluator.evaluate(&corev1.Pod{Spec: corev1.PodSpec{Containers: containers}}, "default")
+		if decision.Allowed || decision.Reason != ReasonMaxGPUMemoryExceeded {
+			t.Errorf("%v: Allowed = %v, Reason = %q, want denial for %q", quantities, decision.Allowed, decision.Reason, ReasonMaxGPUMemoryExceeded)
+		}
+	}
+}
@@ -0,0 +1,985 @@
+// Package policy defines the GPU policy and evaluates pods against it,
+// independently of how the admission requests reach the webhook.
+package policy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/klog/v2"
+)
+
+const migResourcePrefix = "nvidia.com/mig-"
+
+// DefaultMaxGPUsAnnotation lets a pod raise its own per-pod GPU limit, up to
+// MaxGPUsAnnotationCeiling, in namespaces that allow it.
+const DefaultMaxGPUsAnnotation = "gpu-policy/max-gpus"
+
+// DisabledAnnotation on a namespace set to "true" turns off enforcement in it,
+// e.g. during an incident, without redeploying the webhook.
+const DisabledAnnotation = "gpu-policy/disabled"
+
+// OwnerKindStandalone is the owner kind used for pods without a controller.
+const OwnerKindStandalone = "Standalone"
+
+// Enforcement modes.
+const (
+	ModeEnforce = "enforce"
+	ModeWarn    = "warn"
+)
+
+// Policy is the GPU admission policy enforced by the webhook. It is built from
+// the command line flags and optionally overridden by the --config file.
+type Policy struct {
+	// GPUPrefixes lists the resource name patterns treated as GPUs. They are
+	// prefixes unless GPUMatchMode says otherwise.
+	GPUPrefixes []string `json:"gpuPrefixes"`
+	// GPUSuffixes lists resource name suffixes, e.g. /gpu for
+	// example.com/accelerator-gpu, also treated as GPUs. They are always
+	// matched literally, whatever GPUMatchMode is.
+	GPUSuffixes []string `json:"gpuSuffixes,omitempty"`
+	// DeprecatedGPUPrefixes maps resource prefixes, e.g. amd.com during a
+	// migration away from AMD GPUs, to the message denying new pods that
+	// request them. An empty message uses a default one. They are matched
+	// independently of GPUPrefixes.
+	DeprecatedGPUPrefixes map[string]string `json:"deprecatedGPUPrefixes,omitempty"`
+	// GPUMatchMode is MatchModePrefix (the default), MatchModeGlob or
+	// MatchModeRegex.
+	GPUMatchMode string `json:"gpuMatchMode,omitempty"`
+	// MaxGPUsPerPod caps the GPUs a single pod may request. Negative denies
+	// any GPU request.
+	MaxGPUsPerPod int64 `json:"maxGPUsPerPod"`
+	// MaxGPUsPerContainer caps the full GPUs any single container may
+	// request, independently of the per-pod total. Nil disables the limit.
+	MaxGPUsPerContainer *int64 `json:"maxGPUsPerContainer,omitempty"`
+	// MaxGPUsPerPodByOwnerKind overrides MaxGPUsPerPod by the kind of the
+	// pod's controller, e.g. Job or ReplicaSet, with OwnerKindStandalone for
+	// bare pods.
+	MaxGPUsPerPodByOwnerKind map[string]int64 `json:"maxGPUsPerPodByOwnerKind,omitempty"`
+	// MaxGPUsAnnotation names the pod annotation overriding the per-pod GPU
+	// limit, e.g. gpu-policy/max-gpus: "4". It only raises the limit in
+	// namespaces with AllowMaxGPUsAnnotation and can only lower it elsewhere.
+	MaxGPUsAnnotation string `json:"maxGPUsAnnotation,omitempty"`
+	// MaxGPUsAnnotationCeiling is the highest limit MaxGPUsAnnotation may
+	// grant. Negative ignores the annotation.
+	MaxGPUsAnnotationCeiling int64 `json:"maxGPUsAnnotationCeiling"`
+	// MaxMIGDevicesPerPod caps the nvidia.com/mig-* slices a single pod may
+	// request. When negative, MIG slices matched by GPUPrefixes are counted as
+	// full GPUs against MaxGPUsPerPod.
+	MaxMIGDevicesPerPod int64 `json:"maxMIGDevicesPerPod"`
+	// TimeSlicedResources lists resources advertised as time-sliced replicas
+	// of a GPU, such as nvidia.com/gpu.shared. They are limited by
+	// MaxTimeSlicedReplicasPerPod rather than counted as GPUs.
+	TimeSlicedResources []string `json:"timeSlicedResources,omitempty"`
+	// TimeSlicedNamespaces lists namespaces whose GPUs are all time-sliced
+	// replicas, typically because they are pinned to a node pool where the
+	// device plugin shares every GPU. It is set by the operator rather than
+	// the pod, so pods cannot opt out of the exclusive GPU limits.
+	TimeSlicedNamespaces []string `json:"timeSlicedNamespaces,omitempty"`
+	// MaxTimeSlicedReplicasPerPod caps the time-sliced replicas a single pod
+	// may request. Negative disables time-slicing support, and replicas are
+	// counted as full GPUs.
+	MaxTimeSlicedReplicasPerPod int64 `json:"maxTimeSlicedReplicasPerPod"`
+	// GPUMemoryResources lists resources that express GPU memory, such as
+	// nvidia.com/gpu-memory. They are limited by MaxGPUMemory rather than
+	// counted as GPUs.
+	GPUMemoryResources []string `json:"gpuMemoryResources,omitempty"`
+	// MaxGPUMemory caps the GPU memory a single pod may request. Nil disables
+	// the limit.
+	MaxGPUMemory *resource.Quantity `json:"maxGPUMemory,omitempty"`
+	// GPUMemoryUnit is the size of one unit of a whole decimal GPU memory
+	// quantity, e.g. 1Mi for plugins that advertise memory in MiB. Quantities
+	// with a binary suffix such as 8Gi are already in bytes.
+	GPUMemoryUnit *resource.Quantity `json:"gpuMemoryUnit,omitempty"`
+	// FractionalGPUResources maps resources expressing a share of a GPU, such
+	// as tencent.com/vcuda-core, to the quantity that makes up one whole GPU,
+	// e.g. 100. They are limited by MaxFractionalGPUs rather than counted as
+	// GPUs, and must also match GPUPrefixes.
+	FractionalGPUResources map[string]int64 `json:"fractionalGPUResources,omitempty"`
+	// MaxFractionalGPUs caps the fractional GPU resources a single pod may
+	// request, in whole GPUs, e.g. 1.5. Nil disables the limit.
+	MaxFractionalGPUs *resource.Quantity `json:"maxFractionalGPUs,omitempty"`
+	// MaxGPUsPerNamespace caps the GPUs requested by all running pods in a
+	// namespace. Negative disables the quota.
+	MaxGPUsPerNamespace int64 `json:"maxGPUsPerNamespace"`
+	// Schedule restricts when new GPU pods are admitted. Nil admits them at
+	// any time.
+	Schedule *Schedule `json:"schedule,omitempty"`
+	// Namespaces holds per-namespace overrides keyed by namespace name.
+	Namespaces map[string]NamespacePolicy `json:"namespaces,omitempty"`
+	// SkipNamespaces lists namespaces, typically cluster infrastructure such
+	// as kube-system, whose pods are always allowed.
+	SkipNamespaces []string `json:"skipNamespaces,omitempty"`
+	// ExemptServiceAccounts lists service accounts, in namespace/name form,
+	// whose pods bypass the policy.
+	ExemptServiceAccounts []string `json:"exemptServiceAccounts,omitempty"`
+	// ExemptPriorityClasses lists priority class names whose pods bypass the
+	// policy.
+	ExemptPriorityClasses []string `json:"exemptPriorityClasses,omitempty"`
+	// MinExemptPriority exempts pods whose numeric priority is at least this
+	// value.
+	MinExemptPriority *int32 `json:"minExemptPriority,omitempty"`
+	// NodeSelector is added to GPU pods by the mutating webhook.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations are added to pods requesting a resource under the GPU
+	// prefix they are keyed by, so they can land on tainted GPU nodes.
+	Tolerations map[string][]corev1.Toleration `json:"tolerations,omitempty"`
+	// AllowedProducts restricts which GPU products pods may select through
+	// GPUProductLabel. Empty allows every product.
+	AllowedProducts []string `json:"allowedProducts,omitempty"`
+	// AllowedGPUImageRegistries restricts the registries that images of
+	// GPU-requesting containers may come from. Empty allows every registry.
+	AllowedGPUImageRegistries []string `json:"allowedGPUImageRegistries,omitempty"`
+	// AllowedRuntimeClasses lists the runtime classes GPU pods must select
+	// through spec.runtimeClassName. Empty does not require one.
+	AllowedRuntimeClasses []string `json:"allowedRuntimeClasses,omitempty"`
+	// GPUProductLabel is the node label pods use to select a GPU product.
+	GPUProductLabel string `json:"gpuProductLabel,omitempty"`
+	// AllowUnspecifiedProduct admits pods that do not select a product when
+	// AllowedProducts is set.
+	AllowUnspecifiedProduct bool `json:"allowUnspecifiedProduct"`
+	// GPUNodeAffinity maps GPU resources, e.g. amd.com/gpu, to the node label
+	// requirement pods requesting them must carry in their nodeSelector or
+	// required node affinity to reach the matching node pool.
+	GPUNodeAffinity map[corev1.ResourceName]corev1.NodeSelectorRequirement `json:"gpuNodeAffinity,omitempty"`
+	// RequiredGPULabel is a label, e.g. finance/cost-center, that GPU pods
+	// must carry. Empty does not require one.
+	RequiredGPULabel string `json:"requiredGPULabel,omitempty"`
+	// RequiredGPULabelPattern is a regular expression the whole value of
+	// RequiredGPULabel must match. Empty accepts any non-empty value.
+	RequiredGPULabelPattern string `json:"requiredGPULabelPattern,omitempty"`
+	// PodSelector is a label selector, e.g. "team in (ml, research)", limiting
+	// the policy to matching pods. Empty selects every pod.
+	PodSelector string `json:"podSelector,omitempty"`
+	// StrictZeroGPURequests treats a GPU resource requested with a quantity of
+	// zero, e.g. nvidia.com/gpu: 0, as a GPU request. By default such pods
+	// request no GPU.
+	StrictZeroGPURequests bool `json:"strictZeroGPURequests,omitempty"`
+	// ApplyDefaults evaluates pods after applying the API server's resource
+	// defaulting, see DefaultedPod.
+	ApplyDefaults bool `json:"applyDefaults,omitempty"`
+	// DenyUnlistedAccelerators denies pods requesting an extended resource
+	// that looks like an accelerator (gpu, tpu or fpga in its name) but is not
+	// covered by GPUPrefixes or GPUMemoryResources.
+	DenyUnlistedAccelerators bool `json:"denyUnlistedAccelerators"`
+	// RequireGPULimits denies GPU containers that set no resource limits at
+	// all, leaving them without a Guaranteed QoS class.
+	RequireGPULimits bool `json:"requireGPULimits,omitempty"`
+	// MinGPUContainerCPU and MinGPUContainerMemory are the least CPU and
+	// memory, e.g. 2 and 8Gi, that every container requesting a GPU must
+	// request so it does not starve the GPU. Nil disables the floor.
+	MinGPUContainerCPU    *resource.Quantity `json:"minGPUContainerCPU,omitempty"`
+	MinGPUContainerMemory *resource.Quantity `json:"minGPUContainerMemory,omitempty"`
+	// Mode is either ModeEnforce or ModeWarn. In warn mode violations are
+	// reported as admission warnings and the pod is allowed.
+	Mode string `json:"mode"`
+	// DenyMessageTemplate is a text/template rendered into the denial message.
+	// See denyMessageData for the available fields.
+	DenyMessageTemplate string `json:"denyMessageTemplate,omitempty"`
+
+	denyTemplate *template.Template
+	matchers     map[string]resourceMatcher
+	podSelector  labels.Selector
+	// requiredLabelPattern is the compiled RequiredGPULabelPattern.
+	requiredLabelPattern *regexp.Regexp
+	hash                 string
+	// namespaceViews holds a copy of the policy for each namespace that
+	// overrides the GPU prefixes, see ForNamespace.
+	namespaceViews map[string]*Policy
+
+	// name and path identify a named policy from the config file. routes
+	// holds the named policies of the default policy, keyed by path.
+	name   string
+	path   string
+	routes map[string]*Policy
+}
+
+// clone returns a deep copy of the exported fields, so a policy file can be
+// decoded on top of it without modifying the original's maps and slices.
+func (p Policy) clone() (Policy, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return Policy{}, fmt.Errorf("copy policy: %w", err)
+	}
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return Policy{}, fmt.Errorf("copy policy: %w", err)
+	}
+	return policy, nil
+}
+
+// ForNamespace returns the policy as seen by pods in namespace: a copy with
+// the namespace's GPU prefixes when it overrides or extends them, and p
+// itself otherwise.
+func (p *Policy) ForNamespace(namespace string) *Policy {
+	if view, ok := p.namespaceViews[namespace]; ok {
+		return view
+	}
+	return p
+}
+
+// ForPath returns the named policy served on path, or nil if there is none.
+func (p *Policy) ForPath(path string) *Policy {
+	return p.routes[path]
+}
+
+// Routes returns the named policies keyed by the path they are served on.
+func (p *Policy) Routes() map[string]*Policy {
+	return p.routes
+}
+
+// Hash identifies the policy's configuration, so that decisions can be
+// correlated with the config version that made them. It is empty until
+// Prepare has been called.
+func (p *Policy) Hash() string {
+	return p.hash
+}
+
+// DisplayName identifies the policy in logs and audit entries.
+func (p *Policy) DisplayName() string {
+	if p.name == "" {
+		return "default"
+	}
+	return p.name
+}
+
+// denyMessageData is passed to DenyMessageTemplate.
+type denyMessageData struct {
+	Namespace string
+	// PodName is the pod's PodDisplayName.
+	PodName string
+	// Resource is every GPU resource the pod requests, joined with ", ",
+	// and Resources the same names as a list.
+	Resource  string
+	Resources []string
+	Limit     int64
+	Reason    string
+	// Message is the default denial message.
+	Message string
+}
+
+// Prepare compiles the parts of the policy that are reused on every request.
+// A template that fails to parse is logged and the default message is used.
+func (p *Policy) Prepare() {
+	if data, err := json.Marshal(p); err == nil {
+		sum := sha256.Sum256(data)
+		p.hash = hex.EncodeToString(sum[:6])
+	}
+	// Validate has already rejected patterns that do not compile.
+	p.matchers, _ = compileMatchers(p.GPUMatchMode, p.GPUPrefixes)
+	p.requiredLabelPattern, _ = p.compileRequiredLabelPattern()
+	p.podSelector = nil
+	if p.PodSelector != "" {
+		p.podSelector, _ = labels.Parse(p.PodSelector)
+	}
+	if p.Schedule != nil {
+		p.Schedule.compile()
+	}
+	p.namespaceViews = nil
+	for name, ns := range p.Namespaces {
+		if ns.Schedule != nil {
+			ns.Schedule.compile()
+		}
+		if prefixes := ns.gpuPrefixes(p.GPUPrefixes); prefixes != nil {
+			view := *p
+			view.GPUPrefixes = prefixes
+			view.matchers, _ = compileMatchers(p.GPUMatchMode, prefixes)
+			view.namespaceViews = nil
+			if p.namespaceViews == nil {
+				p.namespaceViews = make(map[string]*Policy)
+			}
+			p.namespaceViews[name] = &view
+		}
+	}
+
+	p.denyTemplate = nil
+	if p.DenyMessageTemplate == "" {
+		return
+	}
+	tmpl, err := template.New("deny").Parse(p.DenyMessageTemplate)
+	if err != nil {
+		klog.Errorf("Failed to parse deny message template, using the default message: %v", err)
+		return
+	}
+	p.denyTemplate = tmpl
+}
+
+// denyMessage renders the deny message template, falling back to the default
+// message in data when no template is configured or it fails to render.
+func (p *Policy) denyMessage(data denyMessageData) string {
+	if p.denyTemplate == nil {
+		return data.Message
+	}
+	var buf bytes.Buffer
+	if err := p.denyTemplate.Execute(&buf, data); err != nil {
+		klog.Errorf("Failed to render deny message template, using the default message: %v", err)
+		return data.Message
+	}
+	return buf.String()
+}
+
+// NamespacePolicy overrides the global policy for a single namespace.
+type NamespacePolicy struct {
+	MaxGPUsPerPod *int64 `json:"maxGPUsPerPod,omitempty"`
+	MaxGPUs       *int64 `json:"maxGPUs,omitempty"`
+	// AllowedProducts overrides the global product allowlist.
+	AllowedProducts []string `json:"allowedProducts,omitempty"`
+	// Schedule overrides the global schedule.
+	Schedule *Schedule `json:"schedule,omitempty"`
+	// GPUPrefixes replaces the global GPU prefixes, e.g. amd.com for a ROCm
+	// namespace.
+	GPUPrefixes []string `json:"gpuPrefixes,omitempty"`
+	// ExtraGPUPrefixes are matched in addition to GPUPrefixes, or to the
+	// global prefixes when GPUPrefixes is empty.
+	ExtraGPUPrefixes []string `json:"extraGPUPrefixes,omitempty"`
+	// AllowMaxGPUsAnnotation lets pods in the namespace raise their per-pod
+	// limit through MaxGPUsAnnotation.
+	AllowMaxGPUsAnnotation bool `json:"allowMaxGPUsAnnotation,omitempty"`
+}
+
+// gpuPrefixes returns the namespace's GPU prefixes given the global ones, or
+// nil when the namespace does not change them.
+func (ns NamespacePolicy) gpuPrefixes(global []string) []string {
+	if len(ns.GPUPrefixes) == 0 && len(ns.ExtraGPUPrefixes) == 0 {
+		return nil
+	}
+	prefixes := global
+	if len(ns.GPUPrefixes) > 0 {
+		prefixes = ns.GPUPrefixes
+	}
+	return append(slices.Clone(prefixes), ns.ExtraGPUPrefixes...)
+}
+
+// Validate checks the policy for obvious mistakes. Negative global limits
+// are meaningful (-1 denies GPUs or disables the quota), but anything below -1
+// is almost certainly a typo.
+func (p *Policy) Validate() error {
+	if p.Mode != ModeEnforce && p.Mode != ModeWarn {
+		return fmt.Errorf("invalid mode %q, must be %s or %s", p.Mode, ModeEnforce, ModeWarn)
+	}
+	if len(p.GPUPrefixes) == 0 && len(p.GPUSuffixes) == 0 {
+		return fmt.Errorf("at least one GPU prefix or suffix is required")
+	}
+	for _, prefix := range p.GPUPrefixes {
+		if strings.TrimSpace(prefix) == "" {
+			return fmt.Errorf("GPU prefixes must not be empty")
+		}
+	}
+	for _, suffix := range p.GPUSuffixes {
+		if strings.TrimSpace(suffix) == "" {
+			return fmt.Errorf("GPU suffixes must not be empty")
+		}
+	}
+	for prefix := range p.DeprecatedGPUPrefixes {
+		if strings.TrimSpace(prefix) == "" {
+			return fmt.Errorf("deprecated GPU prefixes must not be empty")
+		}
+	}
+	if _, err := compileMatchers(p.GPUMatchMode, p.GPUPrefixes); err != nil {
+		return err
+	}
+	if _, err := p.compileRequiredLabelPattern(); err != nil {
+		return err
+	}
+	for resourceName, requirement := range p.GPUNodeAffinity {
+		if err := validateNodeRequirement(requirement); err != nil {
+			return fmt.Errorf("gpuNodeAffinity[%s]: %w", resourceName, err)
+		}
+	}
+	if p.PodSelector != "" {
+		if _, err := labels.Parse(p.PodSelector); err != nil {
+			return fmt.Errorf("invalid pod selector %q: %w", p.PodSelector, err)
+		}
+	}
+	if p.Schedule != nil {
+		if err := p.Schedule.compile(); err != nil {
+			return err
+		}
+	}
+	for name, limit := range map[string]int64{
+		"maxGPUsPerPod":               p.MaxGPUsPerPod,
+		"maxMIGDevicesPerPod":         p.MaxMIGDevicesPerPod,
+		"maxTimeSlicedReplicasPerPod": p.MaxTimeSlicedReplicasPerPod,
+		"maxGPUsPerNamespace":         p.MaxGPUsPerNamespace,
+		"maxGPUsAnnotationCeiling":    p.MaxGPUsAnnotationCeiling,
+	} {
+		if limit < -1 {
+			return fmt.Errorf("%s must be -1 or greater, got %d", name, limit)
+		}
+	}
+	for resourceName, denominator := range p.FractionalGPUResources {
+		if denominator <= 0 {
+			return fmt.Errorf("fractionalGPUResources[%s] must be positive, got %d", resourceName, denominator)
+		}
+	}
+	if p.MaxFractionalGPUs != nil && p.MaxFractionalGPUs.Sign() < 0 {
+		return fmt.Errorf("maxFractionalGPUs must not be negative, got %s", p.MaxFractionalGPUs.String())
+	}
+	if p.MinGPUContainerCPU != nil && p.MinGPUContainerCPU.Sign() < 0 {
+		return fmt.Errorf("minGPUContainerCPU must not be negative, got %s", p.MinGPUContainerCPU.String())
+	}
+	if p.MinGPUContainerMemory != nil && p.MinGPUContainerMemory.Sign() < 0 {
+		return fmt.Errorf("minGPUContainerMemory must not be negative, got %s", p.MinGPUContainerMemory.String())
+	}
+	if p.MaxGPUsPerContainer != nil && *p.MaxGPUsPerContainer < 0 {
+		return fmt.Errorf("maxGPUsPerContainer must not be negative, got %d", *p.MaxGPUsPerContainer)
+	}
+	for kind, limit := range p.MaxGPUsPerPodByOwnerKind {
+		if limit < 0 {
+			return fmt.Errorf("maxGPUsPerPodByOwnerKind[%s] must not be negative, got %d", kind, limit)
+		}
+	}
+	for namespace, ns := range p.Namespaces {
+		if ns.MaxGPUsPerPod != nil && *ns.MaxGPUsPerPod < -1 {
+			return fmt.Errorf("namespaces[%s].maxGPUsPerPod must be -1 or greater, got %d", namespace, *ns.MaxGPUsPerPod)
+		}
+		if ns.MaxGPUs != nil && *ns.MaxGPUs < 0 {
+			return fmt.Errorf("namespaces[%s].maxGPUs quota must not be negative, got %d", namespace, *ns.MaxGPUs)
+		}
+		if ns.Schedule != nil {
+			if err := ns.Schedule.compile(); err != nil {
+				return fmt.Errorf("namespaces[%s]: %w", namespace, err)
+			}
+		}
+		if prefixes := ns.gpuPrefixes(p.GPUPrefixes); prefixes != nil {
+			for _, prefix := range prefixes {
+				if strings.TrimSpace(prefix) == "" {
+					return fmt.Errorf("namespaces[%s]: GPU prefixes must not be empty", namespace)
+				}
+			}
+			if _, err := compileMatchers(p.GPUMatchMode, prefixes); err != nil {
+				return fmt.Errorf("namespaces[%s]: %w", namespace, err)
+			}
+		}
+	}
+	for _, serviceAccount := range p.ExemptServiceAccounts {
+		namespace, name, ok := strings.Cut(serviceAccount, "/")
+		if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+			return fmt.Errorf("invalid exempt service account %q, expected namespace/name", serviceAccount)
+		}
+	}
+	return nil
+}
+
+// selectsPod reports whether the pod is in scope of the policy.
+func (p *Policy) selectsPod(pod *corev1.Pod) bool {
+	return p.podSelector == nil || p.podSelector.Matches(labels.Set(pod.Labels))
+}
+
+// MaxGPUsFor returns the per-pod GPU limit that applies to the pod. A
+// namespace override wins over an owner kind override, which wins over the
+// global limit. A valid MaxGPUsAnnotation replaces that limit in namespaces
+// with AllowMaxGPUsAnnotation and can only lower it elsewhere, so that pods
+// cannot grant themselves GPUs where the override was not opted into.
+func (p *Policy) MaxGPUsFor(pod *corev1.Pod, namespace string) int64 {
+	limit := p.MaxGPUsPerPod
+	if ns, ok := p.Namespaces[namespace]; ok && ns.MaxGPUsPerPod != nil {
+		limit = *ns.MaxGPUsPerPod
+	} else if ownerLimit, ok := p.MaxGPUsPerPodByOwnerKind[podOwnerKind(pod)]; ok {
+		limit = ownerLimit
+	}
+
+	annotated, ok, err := p.annotatedMaxGPUs(pod)
+	if !ok || err != nil {
+		return limit
+	}
+	if p.Namespaces[namespace].AllowMaxGPUsAnnotation || limit < 0 || annotated < limit {
+		return annotated
+	}
+	return limit
+}
+
+// annotatedMaxGPUs returns the per-pod limit requested through
+// MaxGPUsAnnotation. ok is false when the annotation is disabled or absent,
+// and err describes a value that is not an integer within the ceiling.
+func (p *Policy) annotatedMaxGPUs(pod *corev1.Pod) (limit int64, ok bool, err error) {
+	if p.MaxGPUsAnnotationCeiling < 0 || p.MaxGPUsAnnotation == "" {
+		return 0, false, nil
+	}
+	value, found := pod.Annotations[p.MaxGPUsAnnotation]
+	if !found {
+		return 0, false, nil
+	}
+	limit, err = strconv.ParseInt(value, 10, 64)
+	if err != nil || limit < 0 {
+		return 0, true, fmt.Errorf("annotation %s=%q must be a non-negative integer", p.MaxGPUsAnnotation, value)
+	}
+	if limit > p.MaxGPUsAnnotationCeiling {
+		return 0, true, fmt.Errorf("annotation %s=%q exceeds the maximum of %d GPUs per pod", p.MaxGPUsAnnotation, value, p.MaxGPUsAnnotationCeiling)
+	}
+	return limit, true, nil
+}
+
+// podOwnerKind returns the kind of the pod's controller, or OwnerKindStandalone
+// for a bare pod. Pods managed by a Deployment are owned by a ReplicaSet. The
+// pod name may still be empty at admission when generateName is used, so the
+// owner is the reliable way to identify the workload.
+func podOwnerKind(pod *corev1.Pod) string {
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		return owner.Kind
+	}
+	return OwnerKindStandalone
+}
+
+// PodDisplayName identifies the pod in logs and messages. Pods created by
+// controllers have no name yet at admission, so it falls back to the
+// generateName prefix with a * suffix, e.g. train-7f9c-*, then to the
+// controller, e.g. <pod of Job/train>.
+func PodDisplayName(pod *corev1.Pod) string {
+	switch {
+	case pod.Name != "":
+		return pod.Name
+	case pod.GenerateName != "":
+		return pod.GenerateName + "*"
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		return fmt.Sprintf("<pod of %s/%s>", owner.Kind, owner.Name)
+	}
+	return "<unnamed pod>"
+}
+
+// namespaceQuotaFor returns the namespace-wide GPU quota, negative if none.
+func (p *Policy) namespaceQuotaFor(namespace string) int64 {
+	if ns, ok := p.Namespaces[namespace]; ok && ns.MaxGPUs != nil {
+		return *ns.MaxGPUs
+	}
+	return p.MaxGPUsPerNamespace
+}
+
+// HasNamespaceQuota reports whether any namespace-wide GPU quota is configured.
+func (p *Policy) HasNamespaceQuota() bool {
+	if p.MaxGPUsPerNamespace >= 0 {
+		return true
+	}
+	for _, ns := range p.Namespaces {
+		if ns.MaxGPUs != nil && *ns.MaxGPUs >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSkippedNamespace reports whether the policy does not apply in namespace.
+func (p *Policy) IsSkippedNamespace(namespace string) bool {
+	return slices.Contains(p.SkipNamespaces, namespace)
+}
+
+// IsExemptServiceAccount reports whether the pod's service account is exempt.
+// Matching is exact and namespace-scoped, so exempting ns-a/foo does not exempt
+// a service account named foo in any other namespace.
+func (p *Policy) IsExemptServiceAccount(pod *corev1.Pod, namespace string) bool {
+	return slices.Contains(p.ExemptServiceAccounts, namespace+"/"+serviceAccountName(pod))
+}
+
+// serviceAccountName returns the service account the pod runs as.
+func serviceAccountName(pod *corev1.Pod) string {
+	if pod.Spec.ServiceAccountName == "" {
+		return "default"
+	}
+	return pod.Spec.ServiceAccountName
+}
+
+// IsGPUResource reports whether the resource matches a GPU prefix or suffix.
+// A resource matching both is still a single resource, so it is counted once.
+func (p *Policy) IsGPUResource(resourceName corev1.ResourceName) bool {
+	for _, prefix := range p.GPUPrefixes {
+		if p.matchesPattern(prefix, resourceName) {
+			return true
+		}
+	}
+	for _, suffix := range p.GPUSuffixes {
+		if strings.HasSuffix(string(resourceName), suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMIGResource reports whether the resource is an NVIDIA MIG slice such as
+// nvidia.com/mig-1g.5gb.
+func isMIGResource(resourceName corev1.ResourceName) bool {
+	return strings.HasPrefix(string(resourceName), migResourcePrefix)
+}
+
+// separateMIG reports whether MIG slices have their own per-pod limit instead of
+// being counted as full GPUs.
+func (p *Policy) separateMIG() bool {
+	return p.MaxMIGDevicesPerPod >= 0
+}
+
+// isFullGPUResource reports whether the resource counts against MaxGPUsPerPod.
+func (p *Policy) isFullGPUResource(resourceName corev1.ResourceName) bool {
+	return p.IsGPUResource(resourceName) && !p.isGPUMemoryResource(resourceName) && !p.isFractionalGPUResource(resourceName) && !(p.separateMIG() && isMIGResource(resourceName)) && !p.isTimeSlicedResource(resourceName)
+}
+
+// FindGPUResource returns the first of GPUResources, if the pod requests any
+// GPU resource.
+func (p *Policy) FindGPUResource(pod *corev1.Pod) (corev1.ResourceName, bool) {
+	resourceNames := p.GPUResources(pod)
+	if len(resourceNames) == 0 {
+		return "", false
+	}
+	return resourceNames[0], true
+}
+
+// GPUResources returns the GPU resources requested by any container or at the
+// pod level, sorted by name. A quantity of zero requests no GPU and is
+// skipped unless StrictZeroGPURequests is set.
+func (p *Policy) GPUResources(pod *corev1.Pod) []corev1.ResourceName {
+	seen := make(map[corev1.ResourceName]bool)
+	for _, container := range allContainers(pod) {
+		p.addGPUResources(seen, EffectiveRequests(container.Resources))
+	}
+	p.addGPUResources(seen, podLevelRequests(pod))
+	return sortedResourceNames(seen)
+}
+
+// addGPUResources adds the GPU resources requested in resources to seen.
+func (p *Policy) addGPUResources(seen map[corev1.ResourceName]bool, resources corev1.ResourceList) {
+	for resourceName, quantity := range resources {
+		if (p.StrictZeroGPURequests || !quantity.IsZero()) && p.IsGPUResource(resourceName) {
+			seen[resourceName] = true
+		}
+	}
+}
+
+// findResource returns the first resource requested by any container, or at
+// the pod level, that satisfies match.
+func findResource(pod *corev1.Pod, match func(corev1.ResourceName) bool) (corev1.ResourceName, bool) {
+	for _, container := range allContainers(pod) {
+		if resourceName, ok := findResourceIn(EffectiveRequests(container.Resources), match); ok {
+			return resourceName, true
+		}
+	}
+	return findResourceIn(podLevelRequests(pod), match)
+}
+
+// findResources returns every resource requested by any container, or at the
+// pod level, that satisfies match, sorted by name.
+func findResources(pod *corev1.Pod, match func(corev1.ResourceName) bool) []corev1.ResourceName {
+	seen := make(map[corev1.ResourceName]bool)
+	add := func(resources corev1.ResourceList) {
+		for resourceName := range resources {
+			if match(resourceName) {
+				seen[resourceName] = true
+			}
+		}
+	}
+	for _, container := range allContainers(pod) {
+		add(EffectiveRequests(container.Resources))
+	}
+	add(podLevelRequests(pod))
+	return sortedResourceNames(seen)
+}
+
+func sortedResourceNames(set map[corev1.ResourceName]bool) []corev1.ResourceName {
+	resourceNames := make([]corev1.ResourceName, 0, len(set))
+	for resourceName := range set {
+		resourceNames = append(resourceNames, resourceName)
+	}
+	slices.Sort(resourceNames)
+	return resourceNames
+}
+
+// resourceNameStrings converts resource names for messages and templates.
+func resourceNameStrings(resourceNames []corev1.ResourceName) []string {
+	names := make([]string, len(resourceNames))
+	for i, resourceName := range resourceNames {
+		names[i] = string(resourceName)
+	}
+	return names
+}
+
+// findResourceIn returns the first resource in the list that satisfies match.
+func findResourceIn(resources corev1.ResourceList, match func(corev1.ResourceName) bool) (corev1.ResourceName, bool) {
+	for resourceName := range resources {
+		if match(resourceName) {
+			return resourceName, true
+		}
+	}
+	return "", false
+}
+
+// RequestedPrefixes returns the GPU prefixes, in configured order, that match a
+// resource requested by any container or at the pod level.
+func (p *Policy) RequestedPrefixes(pod *corev1.Pod) []string {
+	var prefixes []string
+	for _, prefix := range p.GPUPrefixes {
+		if _, ok := findResource(pod, func(resourceName corev1.ResourceName) bool {
+			return p.matchesPattern(prefix, resourceName)
+		}); ok {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// checkGPULimitsSet verifies that every container requesting a GPU sets
+// resource limits. It runs before checkLimitsMatchRequests, which would
+// otherwise report the missing GPU limit on its own.
+func (p *Policy) checkGPULimitsSet(pod *corev1.Pod) error {
+	if !p.RequireGPULimits {
+		return nil
+	}
+	for _, container := range allContainers(pod) {
+		if len(container.Resources.Limits) > 0 {
+			continue
+		}
+		if resourceName, ok := findResourceIn(container.Resources.Requests, p.IsGPUResource); ok {
+			request := container.Resources.Requests[resourceName]
+			return fmt.Errorf("container %s requests %s %s but sets no resource limits; set limits for %s equal to its request, and for cpu and memory, so the pod gets the Guaranteed QoS class",
+				container.Name, request.String(), resourceName, resourceName)
+		}
+	}
+	return nil
+}
+
+// checkMinResources verifies that every container requesting a GPU requests
+// at least MinGPUContainerCPU and MinGPUContainerMemory.
+func (p *Policy) checkMinResources(pod *corev1.Pod) error {
+	if p.MinGPUContainerCPU == nil && p.MinGPUContainerMemory == nil {
+		return nil
+	}
+	floors := []struct {
+		name  corev1.ResourceName
+		floor *resource.Quantity
+	}{
+		{name: corev1.ResourceCPU, floor: p.MinGPUContainerCPU},
+		{name: corev1.ResourceMemory, floor: p.MinGPUContainerMemory},
+	}
+	for _, container := range allContainers(pod) {
+		seen := make(map[corev1.ResourceName]bool)
+		p.addGPUResources(seen, EffectiveRequests(container.Resources))
+		if len(seen) == 0 {
+			continue
+		}
+		var requested, required []string
+		for _, f := range floors {
+			if f.floor == nil {
+				continue
+			}
+			// The API server defaults a missing request to the limit.
+			request, ok := container.Resources.Requests[f.name]
+			if !ok {
+				request, ok = container.Resources.Limits[f.name]
+			}
+			if ok && request.Cmp(*f.floor) >= 0 {
+				continue
+			}
+			if ok {
+				requested = append(requested, fmt.Sprintf("%s %s", request.String(), f.name))
+			} else {
+				requested = append(requested, fmt.Sprintf("no %s", f.name))
+			}
+			required = append(required, fmt.Sprintf("%s %s", f.floor.String(), f.name))
+		}
+		if len(required) > 0 {
+			return fmt.Errorf("container %s requests %s with %s; containers requesting GPUs must request at least %s so they do not starve the GPU",
+				container.Name, strings.Join(resourceNameStrings(sortedResourceNames(seen)), ", "), strings.Join(requested, " and "), strings.Join(required, " and "))
+		}
+	}
+	return nil
+}
+
+// checkLimitsMatchRequests verifies the device plugin contract that every GPU
+// resource has a limit equal to its request.
+func (p *Policy) checkLimitsMatchRequests(pod *corev1.Pod) error {
+	for _, container := range allContainers(pod) {
+		for resourceName, request := range container.Resources.Requests {
+			if !p.IsGPUResource(resourceName) {
+				continue
+			}
+			limit, ok := container.Resources.Limits[resourceName]
+			if !ok {
+				return fmt.Errorf("container %s requests %s %s but sets no limit; GPU limits must equal requests", container.Name, request.String(), resourceName)
+			}
+			if limit.Cmp(request) != 0 {
+				return fmt.Errorf("container %s requests %s %s but limits it to %s; GPU limits must equal requests", container.Name, request.String(), resourceName, limit.String())
+			}
+		}
+	}
+	return nil
+}
+
+// sumRequests sums the quantities of all resources in the list that satisfy
+// match.
+func sumRequests(requests corev1.ResourceList, match func(corev1.ResourceName) bool) int64 {
+	var total int64
+	for resourceName, quantity := range requests {
+		if match(resourceName) {
+			total += quantity.Value()
+		}
+	}
+	return total
+}
+
+// PodGPURequests returns the effective number of full GPUs requested by the pod.
+func (p *Policy) PodGPURequests(pod *corev1.Pod) int64 {
+	return podRequests(pod, p.isFullGPUResource)
+}
+
+// containerOverGPULimit returns the first container requesting more full GPUs
+// than MaxGPUsPerContainer, with the field path of the GPU resource and the
+// number of GPUs it requests.
+func (p *Policy) containerOverGPULimit(pod *corev1.Pod) (name, field string, gpus int64, found bool) {
+	if p.MaxGPUsPerContainer == nil {
+		return "", "", 0, false
+	}
+	check := func(path string, index int, container corev1.Container) bool {
+		requests := EffectiveRequests(container.Resources)
+		gpus = sumRequests(requests, p.isFullGPUResource)
+		if gpus <= *p.MaxGPUsPerContainer {
+			return false
+		}
+		resourceName, _ := findResourceIn(requests, p.isFullGPUResource)
+		name, field = container.Name, fmt.Sprintf("%s[%d].resources.requests[%s]", path, index, resourceName)
+		return true
+	}
+	for i, container := range pod.Spec.InitContainers {
+		if check("spec.initContainers", i, container) {
+			return name, field, gpus, true
+		}
+	}
+	for i, container := range pod.Spec.Containers {
+		if check("spec.containers", i, container) {
+			return name, field, gpus, true
+		}
+	}
+	for i, container := range pod.Spec.EphemeralContainers {
+		if check("spec.ephemeralContainers", i, corev1.Container(container.EphemeralContainerCommon)) {
+			return name, field, gpus, true
+		}
+	}
+	return "", "", 0, false
+}
+
+// PodGPURequestsByResource returns the effective quantity requested by the pod
+// of each GPU resource it requests.
+func (p *Policy) PodGPURequestsByResource(pod *corev1.Pod) map[corev1.ResourceName]int64 {
+	requests := make(map[corev1.ResourceName]int64)
+	add := func(resources corev1.ResourceList) {
+		for resourceName := range resources {
+			if _, ok := requests[resourceName]; ok || !p.IsGPUResource(resourceName) {
+				continue
+			}
+			requests[resourceName] = podRequests(pod, func(name corev1.ResourceName) bool {
+				return name == resourceName
+			})
+		}
+	}
+	for _, container := range allContainers(pod) {
+		add(EffectiveRequests(container.Resources))
+	}
+	add(podLevelRequests(pod))
+	return requests
+}
+
+// PodMIGRequests returns the effective number of MIG slices requested by the
+// pod.
+func (p *Policy) PodMIGRequests(pod *corev1.Pod) int64 {
+	return podRequests(pod, func(resourceName corev1.ResourceName) bool {
+		return p.IsGPUResource(resourceName) && isMIGResource(resourceName)
+	})
+}
+
+// podRequests returns the effective quantity of the matching resources
+// requested by the pod. Init containers run sequentially before the regular
+// containers, so like the scheduler we take the larger of the biggest init
+// container and the sum of the regular containers rather than adding them
+// together. Sidecars, init containers with restartPolicy Always, keep running
+// once started, so they are added to every init container after them and to
+// the regular containers. Ephemeral containers run alongside the regular ones
+// and are summed with them. Pod-level requests cover all containers, so they
+// replace the container total when larger.
+func podRequests(pod *corev1.Pod, match func(corev1.ResourceName) bool) int64 {
+	return podTotal(pod, func(requests corev1.ResourceList) int64 {
+		return sumRequests(requests, match)
+	})
+}
+
+// podTotal applies the semantics of podRequests to any per-container sum.
+func podTotal(pod *corev1.Pod, sum func(corev1.ResourceList) int64) int64 {
+	var init, sidecars int64
+	for _, container := range pod.Spec.InitContainers {
+		requests := sum(EffectiveRequests(container.Resources))
+		if container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			sidecars = addSaturating(sidecars, requests)
+			init = max(init, sidecars)
+			continue
+		}
+		init = max(init, addSaturating(sidecars, requests))
+	}
+	regular := sidecars
+	for _, container := range pod.Spec.Containers {
+		regular = addSaturating(regular, sum(EffectiveRequests(container.Resources)))
+	}
+	for _, container := range pod.Spec.EphemeralContainers {
+		regular = addSaturating(regular, sum(EffectiveRequests(container.Resources)))
+	}
+	return max(regular, init, sum(podLevelRequests(pod)))
+}
+
+// addSaturating adds two non-negative totals, saturating at math.MaxInt64
+// rather than wrapping around.
+func addSaturating(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+// podLevelRequests returns the effective pod-level requests in
+// spec.resources, nil if the pod sets none.
+func podLevelRequests(pod *corev1.Pod) corev1.ResourceList {
+	if pod.Spec.Resources == nil {
+		return nil
+	}
+	return EffectiveRequests(*pod.Spec.Resources)
+}
+
+// EffectiveRequests returns the requests a container will be admitted with.
+// GPUs are often set as limits only, and the API server defaults the request
+// of such resources to the limit after the webhook has seen the pod, so each
+// resource counts with the larger of its request and limit.
+func EffectiveRequests(resources corev1.ResourceRequirements) corev1.ResourceList {
+	if len(resources.Limits) == 0 {
+		return resources.Requests
+	}
+	effective := make(corev1.ResourceList, len(resources.Requests)+len(resources.Limits))
+	for resourceName, request := range resources.Requests {
+		effective[resourceName] = request
+	}
+	for resourceName, limit := range resources.Limits {
+		if request, ok := effective[resourceName]; !ok || limit.Cmp(request) > 0 {
+			effective[resourceName] = limit
+		}
+	}
+	return effective
+}
+
+// allContainers returns the regular, init and ephemeral containers of the pod.
+// Ephemeral containers are added through the pods/ephemeralcontainers
+// subresource, so the webhook must also be registered for that subresource
+// for them to be checked after the pod is created.
+func allContainers(pod *corev1.Pod) []corev1.Container {
+	containers := make([]corev1.Container, 0, len(pod.Spec.Containers)+len(pod.Spec.InitContainers)+len(pod.Spec.EphemeralContainers))
+	containers = append(containers, pod.Spec.Containers...)
+	containers = append(containers, pod.Spec.InitContainers...)
+	for _, container := range pod.Spec.EphemeralContainers {
+		containers = append(containers, corev1.Container(container.EphemeralContainerCommon))
+	}
+	return containers
+}
@@ -0,0 +1,46 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// exemptPriority reports whether the pod's priority class exempts it from the
+// policy, either by name or because its priority reaches MinExemptPriority,
+// and returns the rule that applied.
+func (e *Evaluator) exemptPriority(ctx context.Context, policy *Policy, pod *corev1.Pod) (string, bool) {
+	className := pod.Spec.PriorityClassName
+	if className != "" && contains(policy.ExemptPriorityClasses, className) {
+		return fmt.Sprintf("exemptPriorityClasses contains %s", className), true
+	}
+	if policy.MinExemptPriority == nil {
+		return "", false
+	}
+
+	priority, ok := e.podPriority(ctx, pod)
+	if !ok || priority < *policy.MinExemptPriority {
+		return "", false
+	}
+	return fmt.Sprintf("priority %d reaches minExemptPriority %d", priority, *policy.MinExemptPriority), true
+}
+
+// podPriority returns the numeric priority of the pod. The Priority admission
+// plugin normally resolves it before webhooks run, otherwise it is looked up
+// from the PriorityClass.
+func (e *Evaluator) podPriority(ctx context.Context, pod *corev1.Pod) (int32, bool) {
+	if pod.Spec.Priority != nil {
+		return *pod.Spec.Priority, true
+	}
+	if pod.Spec.PriorityClassName == "" {
+		return 0, true
+	}
+	class, err := e.Cluster.GetPriorityClass(ctx, pod.Spec.PriorityClassName)
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Failed to get priority class", "priorityClass", pod.Spec.PriorityClassName)
+		return 0, false
+	}
+	return class.Value, true
+}
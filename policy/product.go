@@ -0,0 +1,85 @@
+package policy
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// DefaultGPUProductLabel is the node label set by NVIDIA GPU feature discovery.
+const DefaultGPUProductLabel = "nvidia.com/gpu.product"
+
+// requestedGPUProducts returns the GPU products the pod is constrained to via
+// its nodeSelector or required node affinity. specified is false when the pod
+// does not pin a product at all and may therefore land on any GPU.
+func requestedGPUProducts(pod *corev1.Pod, label string) (products []string, specified bool) {
+	if value, ok := pod.Spec.NodeSelector[label]; ok {
+		products = append(products, value)
+		specified = true
+	}
+
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return products, specified
+	}
+	// Node selector terms are ORed, so the pod is only pinned to a product if
+	// every term constrains it.
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	var affinityProducts []string
+	allTermsPinned := len(terms) > 0
+	for _, term := range terms {
+		pinned := false
+		for _, expr := range term.MatchExpressions {
+			if expr.Key == label && expr.Operator == corev1.NodeSelectorOpIn {
+				affinityProducts = append(affinityProducts, expr.Values...)
+				pinned = true
+			}
+		}
+		allTermsPinned = allTermsPinned && pinned
+	}
+	if allTermsPinned {
+		products = append(products, affinityProducts...)
+		specified = true
+	}
+	return products, specified
+}
+
+// checkGPUProducts returns the first requested product that is not allowed.
+// ok is false if the pod is denied.
+func (p *Policy) checkGPUProducts(pod *corev1.Pod, namespace string) (product string, ok bool) {
+	allowed := p.allowedProductsFor(namespace)
+	if len(allowed) == 0 {
+		return "", true
+	}
+	products, specified := requestedGPUProducts(pod, p.productLabel())
+	if !specified {
+		return "", p.AllowUnspecifiedProduct
+	}
+	for _, product := range products {
+		if !contains(allowed, product) {
+			return product, false
+		}
+	}
+	return "", true
+}
+
+func (p *Policy) productLabel() string {
+	if p.GPUProductLabel == "" {
+		return DefaultGPUProductLabel
+	}
+	return p.GPUProductLabel
+}
+
+func (p *Policy) allowedProductsFor(namespace string) []string {
+	if ns, ok := p.Namespaces[namespace]; ok && ns.AllowedProducts != nil {
+		return ns.AllowedProducts
+	}
+	return p.AllowedProducts
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package policy
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestCheckGPUProducts(t *testing.T) {
+	policy := &Policy{AllowedProducts: []string{"Tesla-T4"}}
+
+	affinity := func(terms ...[]string) *corev1.Affinity {
+		var selectorTerms []corev1.NodeSelectorTerm
+		for _, values := range terms {
+			selectorTerms = append(selectorTerms, corev1.NodeSelectorTerm{
+				MatchExpressions: []corev1.NodeSelectorRequirement{{
+					Key:      DefaultGPUProductLabel,
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   values,
+				}},
+			})
+		}
+		return &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: selectorTerms},
+		}}
+	}
+
+	tests := []struct {
+		name             string
+		spec             corev1.PodSpec
+		allowUnspecified bool
+		wantOK           bool
+	}{
+		{
+			name:   "allowed product in nodeSelector",
+			spec:   corev1.PodSpec{NodeSelector: map[string]string{DefaultGPUProductLabel: "Tesla-T4"}},
+			wantOK: true,
+		},
+		{
+			name: "denied product in nodeSelector",
+			spec: corev1.PodSpec{NodeSelector: map[string]string{DefaultGPUProductLabel: "NVIDIA-A100-SXM4-40GB"}},
+		},
+		{
+			name:   "allowed product in node affinity",
+			spec:   corev1.PodSpec{Affinity: affinity([]string{"Tesla-T4"})},
+			wantOK: true,
+		},
+		{
+			name: "any denied product in node affinity",
+			spec: corev1.PodSpec{Affinity: affinity([]string{"Tesla-T4"}, []string{"NVIDIA-A100-SXM4-40GB"})},
+		},
+		{
+			name: "unspecified product denied",
+			spec: corev1.PodSpec{},
+		},
+		{
+			name:             "unspecified product allowed",
+			spec:             corev1.PodSpec{},
+			allowUnspecified: true,
+			wantOK:           true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy.AllowUnspecifiedProduct = tt.allowUnspecified
+			_, ok := policy.checkGPUProducts(&corev1.Pod{Spec: tt.spec}, "dev")
+			if ok != tt.wantOK {
+				t.Errorf("ok = %v, want %v", ok, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// namespaceGPUUsage sums the GPUs requested by the pods already running in the
+// namespace. Pods that have finished no longer hold their GPUs, and the pod
+// being admitted is skipped so that updates are not counted twice.
+func (e *Evaluator) namespaceGPUUsage(ctx context.Context, policy *Policy, pod *corev1.Pod, namespace string) (int64, error) {
+	pods, err := e.Cluster.ListPods(ctx, namespace)
+	if err != nil {
+		return 0, err
+	}
+
+	var used int64
+	for _, existing := range pods {
+		if existing.Status.Phase == corev1.PodSucceeded || existing.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if pod.Name != "" && existing.Name == pod.Name {
+			continue
+		}
+		used += policy.PodGPURequests(existing)
+	}
+	return used, nil
+}
+
+// checkResourceQuotas compares the pod's GPU requests with the remaining
+// capacity of every ResourceQuota in the namespace. It returns a message
+// describing the first quota the pod would exceed, or an empty string. The
+// quota's usage already counts a pod that is being updated, so only the GPUs
+// it adds over the existing pod are compared.
+func (e *Evaluator) checkResourceQuotas(ctx context.Context, policy *Policy, pod *corev1.Pod, namespace string) (string, error) {
+	quotas, err := e.Cluster.ListResourceQuotas(ctx, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	var existing *corev1.Pod
+	existingLoaded := false
+	existingRequests := func(resourceName string) (int64, error) {
+		if !existingLoaded {
+			existingLoaded = true
+			if pod.Name != "" {
+				pods, err := e.Cluster.ListPods(ctx, namespace)
+				if err != nil {
+					return 0, err
+				}
+				for _, p := range pods {
+					if p.Name == pod.Name && p.Status.Phase != corev1.PodSucceeded && p.Status.Phase != corev1.PodFailed {
+						existing = p
+						break
+					}
+				}
+			}
+		}
+		if existing == nil {
+			return 0, nil
+		}
+		return podRequests(existing, func(name corev1.ResourceName) bool {
+			return string(name) == resourceName
+		}), nil
+	}
+
+	for _, quota := range quotas {
+		for quotaResource, hard := range quota.Status.Hard {
+			// Extended resources can only be limited through the requests.
+			// prefix, e.g. requests.nvidia.com/gpu.
+			resourceName, ok := strings.CutPrefix(string(quotaResource), corev1.DefaultResourceRequestsPrefix)
+			if !ok || !policy.IsGPUResource(corev1.ResourceName(resourceName)) {
+				continue
+			}
+			requested := podRequests(pod, func(name corev1.ResourceName) bool {
+				return string(name) == resourceName
+			})
+			if requested == 0 {
+				continue
+			}
+			used := quota.Status.Used[quotaResource]
+			remaining := hard.Value() - used.Value()
+			if requested <= remaining {
+				continue
+			}
+			charged, err := existingRequests(resourceName)
+			if err != nil {
+				return "", err
+			}
+			if requested-charged > remaining {
+				return fmt.Sprintf("pod requests %d %s but ResourceQuota %s in namespace %s only has %d remaining (%d of %d used)",
+					requested, resourceName, quota.Name, namespace, max(remaining, 0), used.Value(), hard.Value()), nil
+			}
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,47 @@
+package policy
+
+// Reasons reported with each admission decision.
+const (
+	ReasonNoGPU                       = "no_gpu"
+	ReasonWithinLimit                 = "within_limit"
+	ReasonNamespaceAllowed            = "namespace_allowed"
+	ReasonExemptServiceAccount        = "exempt_service_account"
+	ReasonNamespaceLookupFailed       = "namespace_lookup_failed"
+	ReasonGPUNotAllowed               = "gpu_not_allowed"
+	ReasonMaxGPUsExceeded             = "max_gpus_exceeded"
+	ReasonQuotaLookupFailed           = "quota_lookup_failed"
+	ReasonNamespaceQuotaExceeded      = "namespace_quota_exceeded"
+	ReasonLimitMismatch               = "limit_mismatch"
+	ReasonGPUProductNotAllowed        = "gpu_product_not_allowed"
+	ReasonMaxMIGExceeded              = "max_mig_exceeded"
+	ReasonResourceQuotaExceeded       = "resource_quota_exceeded"
+	ReasonExemptPriority              = "exempt_priority"
+	ReasonImageRegistryNotAllowed     = "image_registry_not_allowed"
+	ReasonMaxGPUMemoryExceeded        = "max_gpu_memory_exceeded"
+	ReasonRuntimeClassNotAllowed      = "runtime_class_not_allowed"
+	ReasonMaxTimeSlicedExceeded       = "max_time_sliced_exceeded"
+	ReasonUnlistedAccelerator         = "unlisted_accelerator"
+	ReasonPodNotSelected              = "pod_not_selected"
+	ReasonInvalidMaxGPUsAnnotation    = "invalid_max_gpus_annotation"
+	ReasonOutsideSchedule             = "outside_schedule"
+	ReasonNamespaceSkipped            = "namespace_skipped"
+	ReasonNodeLookupFailed            = "node_lookup_failed"
+	ReasonExceedsNodeCapacity         = "exceeds_node_capacity"
+	ReasonEnforcementDisabled         = "enforcement_disabled"
+	ReasonRegoAllowed                 = "rego_allowed"
+	ReasonRegoDenied                  = "rego_denied"
+	ReasonRegoFailed                  = "rego_failed"
+	ReasonMaxGPUsPerContainerExceeded = "max_gpus_per_container_exceeded"
+	ReasonGPUsNotIncreased            = "gpus_not_increased"
+	ReasonDeprecatedGPU               = "deprecated_gpu"
+	ReasonRequiredLabelMissing        = "required_label_missing"
+	ReasonDelegateDenied              = "delegate_denied"
+	ReasonDelegateFailed              = "delegate_failed"
+	ReasonGPULimitsMissing            = "gpu_limits_missing"
+	ReasonGPUNodeAffinityMissing      = "gpu_node_affinity_missing"
+	ReasonLimitRangeLookupFailed      = "limit_range_lookup_failed"
+	ReasonMaxFractionalGPUsExceeded   = "max_fractional_gpus_exceeded"
+	ReasonUserBudgetExceeded          = "user_budget_exceeded"
+	ReasonUserBudgetLookupFailed      = "user_budget_lookup_failed"
+	ReasonMinResourcesNotMet          = "min_resources_not_met"
+)
@@ -0,0 +1,55 @@
+package policy
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ResolvedPolicy is the effective policy of a namespace: the policy with the
+// namespace's entry merged, and the settings made by the labels and
+// annotations of its Namespace object.
+type ResolvedPolicy struct {
+	// Policy is the namespace's view of the policy, see ForNamespace.
+	Policy *Policy
+	// Disabled is set by the DisabledAnnotation kill switch.
+	Disabled bool
+	// AllowsGPU is set by the namespace opt-in label.
+	AllowsGPU bool
+}
+
+// PolicyCache caches resolved policies, so that admission requests do not
+// look their namespace up and resolve its policy again. Implementations
+// decide how long entries are kept and when the namespace has changed.
+type PolicyCache interface {
+	// Get returns the resolved policy of policy in namespace, calling
+	// resolve on a miss. Errors are not cached.
+	Get(policy *Policy, namespace string, resolve func() (*ResolvedPolicy, error)) (*ResolvedPolicy, error)
+}
+
+// Resolve returns the effective policy of ns, where policy is the
+// namespace's view of the policy.
+func (e *Evaluator) Resolve(policy *Policy, ns *corev1.Namespace) *ResolvedPolicy {
+	value, ok := ns.Labels[e.AllowLabelKey]
+	return &ResolvedPolicy{
+		Policy:    policy,
+		Disabled:  ns.Annotations[DisabledAnnotation] == "true",
+		AllowsGPU: ok && value == e.AllowLabelValue,
+	}
+}
+
+// resolve returns the effective policy of namespace from e.Policies, looking
+// the namespace up on a miss or without a cache.
+func (e *Evaluator) resolve(ctx context.Context, policy *Policy, namespace string) (*ResolvedPolicy, error) {
+	lookup := func() (*ResolvedPolicy, error) {
+		ns, err := e.Cluster.GetNamespace(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return e.Resolve(policy, ns), nil
+	}
+	if e.Policies == nil {
+		return lookup()
+	}
+	return e.Policies.Get(policy, namespace, lookup)
+}
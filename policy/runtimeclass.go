@@ -0,0 +1,24 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// checkRuntimeClass verifies that a GPU pod selects one of the allowed
+// runtime classes, so it cannot land on a node without the GPU container
+// runtime configured.
+func (p *Policy) checkRuntimeClass(pod *corev1.Pod) error {
+	if len(p.AllowedRuntimeClasses) == 0 {
+		return nil
+	}
+	if pod.Spec.RuntimeClassName == nil || *pod.Spec.RuntimeClassName == "" {
+		return fmt.Errorf("GPU pods must set spec.runtimeClassName to one of %s", strings.Join(p.AllowedRuntimeClasses, ", "))
+	}
+	if !contains(p.AllowedRuntimeClasses, *pod.Spec.RuntimeClassName) {
+		return fmt.Errorf("runtime class %s is not allowed for GPU pods; set spec.runtimeClassName to one of %s", *pod.Spec.RuntimeClassName, strings.Join(p.AllowedRuntimeClasses, ", "))
+	}
+	return nil
+}
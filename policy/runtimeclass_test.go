@@ -0,0 +1,35 @@
+package policy
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestCheckRuntimeClass(t *testing.T) {
+	policy := Policy{AllowedRuntimeClasses: []string{"nvidia"}}
+
+	tests := []struct {
+		name         string
+		runtimeClass *string
+		wantErr      bool
+	}{
+		{name: "allowed", runtimeClass: ptr.To("nvidia")},
+		{name: "missing", wantErr: true},
+		{name: "empty", runtimeClass: ptr.To(""), wantErr: true},
+		{name: "not allowed", runtimeClass: ptr.To("runc"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{Spec: corev1.PodSpec{RuntimeClassName: tt.runtimeClass}}
+			if err := policy.checkRuntimeClass(pod); (err != nil) != tt.wantErr {
+				t.Errorf("checkRuntimeClass() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := (&Policy{}).checkRuntimeClass(&corev1.Pod{}); err != nil {
+		t.Errorf("expected no error without allowed runtime classes, got %v", err)
+	}
+}
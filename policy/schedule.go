@@ -0,0 +1,183 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule restricts the times at which new GPU pods are admitted.
+type Schedule struct {
+	// TimeZone is the IANA name, e.g. Europe/Berlin, of the zone the windows
+	// are in. It is required so that the schedule never depends on the time
+	// zone of the webhook's container.
+	TimeZone string `json:"timeZone"`
+	// Windows lists when GPU pods are admitted, e.g. "Mon-Fri 08:00-18:00".
+	// The days are optional, and a window ending before it starts runs past
+	// midnight.
+	Windows []string `json:"windows"`
+
+	location *time.Location
+	windows  []timeWindow
+}
+
+// timeWindow is a parsed Schedule window.
+type timeWindow struct {
+	// days is indexed by time.Weekday.
+	days [7]bool
+	// start and end are minutes since midnight, wall clock.
+	start, end int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// compile parses the time zone and windows.
+func (s *Schedule) compile() error {
+	if s.TimeZone == "" {
+		return fmt.Errorf("schedule time zone is required")
+	}
+	location, err := time.LoadLocation(s.TimeZone)
+	if err != nil {
+		return fmt.Errorf("invalid schedule time zone %q: %w", s.TimeZone, err)
+	}
+	if len(s.Windows) == 0 {
+		return fmt.Errorf("schedule needs at least one window")
+	}
+	windows := make([]timeWindow, 0, len(s.Windows))
+	for _, spec := range s.Windows {
+		window, err := parseTimeWindow(spec)
+		if err != nil {
+			return fmt.Errorf("invalid schedule window %q: %w", spec, err)
+		}
+		windows = append(windows, window)
+	}
+	s.location = location
+	s.windows = windows
+	return nil
+}
+
+// parseTimeWindow parses "[Day[-Day]] HH:MM-HH:MM".
+func parseTimeWindow(spec string) (timeWindow, error) {
+	var window timeWindow
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 1:
+		for day := range window.days {
+			window.days[day] = true
+		}
+	case 2:
+		first, last, isRange := strings.Cut(strings.ToLower(fields[0]), "-")
+		if !isRange {
+			last = first
+		}
+		from, ok := weekdays[first]
+		if !ok {
+			return window, fmt.Errorf("unknown day %q", first)
+		}
+		to, ok := weekdays[last]
+		if !ok {
+			return window, fmt.Errorf("unknown day %q", last)
+		}
+		for day := from; ; day = (day + 1) % 7 {
+			window.days[day] = true
+			if day == to {
+				break
+			}
+		}
+	default:
+		return window, fmt.Errorf("expected [days] HH:MM-HH:MM")
+	}
+
+	start, end, ok := strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return window, fmt.Errorf("expected a HH:MM-HH:MM time range")
+	}
+	var err error
+	if window.start, err = parseClock(start); err != nil {
+		return window, err
+	}
+	if window.end, err = parseClock(end); err != nil {
+		return window, err
+	}
+	if window.start == 24*60 {
+		return window, fmt.Errorf("window must start before 24:00")
+	}
+	if window.start == window.end {
+		return window, fmt.Errorf("window is empty")
+	}
+	return window, nil
+}
+
+// parseClock parses HH:MM into minutes since midnight. 24:00 is accepted as
+// the end of the day.
+func parseClock(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether the wall clock time t falls in the window. The part
+// of a window past midnight belongs to the day it started on.
+func (w timeWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if w.start < w.end {
+		return w.days[day] && minute >= w.start && minute < w.end
+	}
+	return (w.days[day] && minute >= w.start) || (w.days[(day+6)%7] && minute < w.end)
+}
+
+// allows reports whether GPU pods are admitted at t.
+func (s *Schedule) allows(t time.Time) bool {
+	t = t.In(s.location)
+	for _, window := range s.windows {
+		if window.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextOpening returns the start of the first window after t. Windows are
+// resolved to wall clock times in the schedule's zone, so they keep their
+// local start time across DST changes.
+func (s *Schedule) nextOpening(t time.Time) time.Time {
+	t = t.In(s.location)
+	year, month, day := t.Date()
+	var next time.Time
+	for offset := 0; offset <= 7; offset++ {
+		date := time.Date(year, month, day+offset, 0, 0, 0, 0, s.location)
+		for _, window := range s.windows {
+			if !window.days[date.Weekday()] {
+				continue
+			}
+			start := time.Date(year, month, day+offset, window.start/60, window.start%60, 0, 0, s.location)
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+	}
+	return next
+}
+
+// scheduleFor returns the schedule that applies in the namespace, nil if GPU
+// pods are admitted at any time.
+func (p *Policy) scheduleFor(namespace string) *Schedule {
+	if ns, ok := p.Namespaces[namespace]; ok && ns.Schedule != nil {
+		return ns.Schedule
+	}
+	return p.Schedule
+}
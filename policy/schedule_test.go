@@ -0,0 +1,99 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestScheduleAllows(t *testing.T) {
+	schedule := &Schedule{TimeZone: "Europe/Berlin", Windows: []string{"Mon-Fri 08:00-18:00", "Sat 22:00-02:00"}}
+	if err := schedule.compile(); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	tests := []struct {
+		time time.Time
+		want bool
+	}{
+		{time: time.Date(2026, 3, 27, 8, 0, 0, 0, berlin), want: true},      // Friday
+		{time: time.Date(2026, 3, 27, 18, 0, 0, 0, berlin), want: false},    // Friday, end is exclusive
+		{time: time.Date(2026, 3, 27, 7, 0, 0, 0, time.UTC), want: true},    // 08:00 CET
+		{time: time.Date(2026, 3, 30, 7, 0, 0, 0, time.UTC), want: true},    // 09:00 CEST
+		{time: time.Date(2026, 3, 30, 16, 30, 0, 0, time.UTC), want: false}, // 18:30 CEST
+		{time: time.Date(2026, 3, 28, 23, 0, 0, 0, berlin), want: true},     // Saturday night
+		{time: time.Date(2026, 3, 29, 1, 0, 0, 0, berlin), want: true},      // past midnight into Sunday
+		{time: time.Date(2026, 3, 29, 22, 0, 0, 0, berlin), want: false},    // Sunday night
+	}
+	for _, tt := range tests {
+		if got := schedule.allows(tt.time); got != tt.want {
+			t.Errorf("allows(%s) = %v, want %v", tt.time.In(berlin), got, tt.want)
+		}
+	}
+}
+
+func TestScheduleNextOpeningAcrossDST(t *testing.T) {
+	schedule := &Schedule{TimeZone: "Europe/Berlin", Windows: []string{"Mon-Fri 08:00-18:00"}}
+	if err := schedule.compile(); err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	// Clocks go forward on Sunday 2026-03-29, so Monday opens at 06:00 UTC
+	// rather than the 07:00 UTC of the previous week.
+	next := schedule.nextOpening(time.Date(2026, 3, 27, 19, 0, 0, 0, berlin))
+	if want := time.Date(2026, 3, 30, 6, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("nextOpening = %s, want %s", next, want)
+	}
+}
+
+func TestScheduleInvalid(t *testing.T) {
+	for _, schedule := range []Schedule{
+		{Windows: []string{"08:00-18:00"}},
+		{TimeZone: "Mars/Olympus", Windows: []string{"08:00-18:00"}},
+		{TimeZone: "UTC"},
+		{TimeZone: "UTC", Windows: []string{"Mon-Fri"}},
+		{TimeZone: "UTC", Windows: []string{"Funday 08:00-18:00"}},
+		{TimeZone: "UTC", Windows: []string{"08:00-08:00"}},
+		{TimeZone: "UTC", Windows: []string{"8am-6pm"}},
+	} {
+		if err := schedule.compile(); err == nil {
+			t.Errorf("expected an error for %+v", schedule)
+		}
+	}
+}
+
+func TestEvaluateSchedule(t *testing.T) {
+	policy := Policy{
+		GPUPrefixes:         []string{"nvidia.com"},
+		MaxGPUsPerPod:       1,
+		MaxMIGDevicesPerPod: -1,
+		MaxGPUsPerNamespace: -1,
+		Mode:                ModeEnforce,
+		Namespaces: map[string]NamespacePolicy{
+			"dev": {Schedule: &Schedule{TimeZone: "America/New_York", Windows: []string{"Mon-Fri 09:00-17:00"}}},
+		},
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	policy.Prepare()
+	evaluator := newTestEvaluator(policy, testNamespace("dev", nil), testNamespace("prod", nil))
+	// Saturday afternoon in New York.
+	evaluator.Now = func() time.Time { return time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC) }
+
+	pod := corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 1))}}}
+	decision := evaluator.evaluate(&pod, "dev")
+	if decision.Allowed || decision.Reason != ReasonOutsideSchedule {
+		t.Fatalf("got allowed=%v reason=%s, want a denial for %s", decision.Allowed, decision.Reason, ReasonOutsideSchedule)
+	}
+	if want := "Mon 2026-10-19 09:00 EDT"; !strings.Contains(decision.Message, want) {
+		t.Errorf("message = %q, want it to contain %q", decision.Message, want)
+	}
+	if decision := evaluator.evaluate(&pod, "prod"); !decision.Allowed {
+		t.Errorf("expected pod outside the scheduled namespace to be allowed, got %q", decision.Message)
+	}
+}
@@ -0,0 +1,41 @@
+package policy
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// StaticCluster stands in for the cluster when evaluating pods offline, as
+// the eval subcommand and the startup self-test do. Every namespace is
+// Namespace, and there are no other pods, quotas, limit ranges, priority
+// classes or nodes.
+type StaticCluster struct {
+	Namespace *corev1.Namespace
+}
+
+func (c StaticCluster) GetNamespace(ctx context.Context, name string) (*corev1.Namespace, error) {
+	return c.Namespace, nil
+}
+
+func (c StaticCluster) ListPods(ctx context.Context, namespace string) ([]*corev1.Pod, error) {
+	return nil, nil
+}
+
+func (c StaticCluster) ListResourceQuotas(ctx context.Context, namespace string) ([]corev1.ResourceQuota, error) {
+	return nil, nil
+}
+
+func (c StaticCluster) ListLimitRanges(ctx context.Context, namespace string) ([]corev1.LimitRange, error) {
+	return nil, nil
+}
+
+func (c StaticCluster) GetPriorityClass(ctx context.Context, name string) (*schedulingv1.PriorityClass, error) {
+	return nil, apierrors.NewNotFound(schedulingv1.Resource("priorityclasses"), name)
+}
+
+func (c StaticCluster) ListNodes(ctx context.Context) ([]*corev1.Node, error) {
+	return nil, nil
+}
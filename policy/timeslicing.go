@@ -0,0 +1,34 @@
+package policy
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// separateTimeSlicing reports whether time-sliced replicas have their own
+// per-pod limit instead of being counted as full GPUs.
+func (p *Policy) separateTimeSlicing() bool {
+	return p.MaxTimeSlicedReplicasPerPod >= 0
+}
+
+// isTimeSlicedResource reports whether the resource is advertised as
+// time-sliced replicas, e.g. nvidia.com/gpu.shared.
+func (p *Policy) isTimeSlicedResource(resourceName corev1.ResourceName) bool {
+	return p.separateTimeSlicing() && p.IsGPUResource(resourceName) && contains(p.TimeSlicedResources, string(resourceName))
+}
+
+// isTimeSlicedNamespace reports whether all GPUs of pods in namespace are
+// time-sliced replicas.
+func (p *Policy) isTimeSlicedNamespace(namespace string) bool {
+	return p.separateTimeSlicing() && contains(p.TimeSlicedNamespaces, namespace)
+}
+
+// PodTimeSlicedReplicas returns the time-sliced replicas requested by the pod
+// in namespace.
+func (p *Policy) PodTimeSlicedReplicas(pod *corev1.Pod, namespace string) int64 {
+	if p.isTimeSlicedNamespace(namespace) {
+		return podRequests(pod, func(resourceName corev1.ResourceName) bool {
+			return p.isFullGPUResource(resourceName) || p.isTimeSlicedResource(resourceName)
+		})
+	}
+	return podRequests(pod, p.isTimeSlicedResource)
+}
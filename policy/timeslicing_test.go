@@ -0,0 +1,54 @@
+package policy
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateTimeSlicing(t *testing.T) {
+	evaluator := newTestEvaluator(Policy{
+		GPUPrefixes:                 []string{"nvidia.com"},
+		MaxGPUsPerPod:               1,
+		MaxMIGDevicesPerPod:         -1,
+		MaxGPUsPerNamespace:         -1,
+		TimeSlicedResources:         []string{"nvidia.com/gpu.shared"},
+		TimeSlicedNamespaces:        []string{"shared"},
+		MaxTimeSlicedReplicasPerPod: 4,
+	}, testNamespace("default", nil), testNamespace("shared", nil))
+
+	// Pod annotations never make exclusive GPUs count as replicas.
+	annotated := map[string]string{"nvidia.com/device-plugin.config": "sliced"}
+	tests := []struct {
+		name        string
+		namespace   string
+		annotations map[string]string
+		resources   corev1.ResourceList
+		wantAllowed bool
+		wantReason  string
+	}{
+		{name: "replicas within limit", resources: gpus("nvidia.com/gpu.shared", 4), wantAllowed: true, wantReason: ReasonWithinLimit},
+		{name: "replicas over limit", resources: gpus("nvidia.com/gpu.shared", 5), wantReason: ReasonMaxTimeSlicedExceeded},
+		{name: "annotated pod over GPU limit", annotations: annotated, resources: gpus("nvidia.com/gpu", 3), wantReason: ReasonMaxGPUsExceeded},
+		{name: "time-sliced namespace within limit", namespace: "shared", resources: gpus("nvidia.com/gpu", 3), wantAllowed: true, wantReason: ReasonWithinLimit},
+		{name: "time-sliced namespace over limit", namespace: "shared", resources: gpus("nvidia.com/gpu", 5), wantReason: ReasonMaxTimeSlicedExceeded},
+		{name: "exclusive GPUs over limit", resources: gpus("nvidia.com/gpu", 2), wantReason: ReasonMaxGPUsExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			namespace := tt.namespace
+			if namespace == "" {
+				namespace = "default"
+			}
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{container("app", tt.resources)}},
+			}
+			decision := evaluator.evaluate(&pod, namespace)
+			if decision.Allowed != tt.wantAllowed || decision.Reason != tt.wantReason {
+				t.Errorf("got allowed=%v reason=%s, want allowed=%v reason=%s", decision.Allowed, decision.Reason, tt.wantAllowed, tt.wantReason)
+			}
+		})
+	}
+}
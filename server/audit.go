@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// auditEntry is a single JSON line in the audit trail.
+type auditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	UID       types.UID `json:"uid"`
+	User      string    `json:"user"`
+	Groups    []string  `json:"groups,omitempty"`
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Policy    string    `json:"policy,omitempty"`
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message"`
+}
+
+// auditLogger appends denied admissions to a file, or stdout if no path is
+// configured. Writes are serialized so concurrent requests never interleave.
+type auditLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newAuditLogger(path string) (*auditLogger, error) {
+	if path == "" {
+		return &auditLogger{w: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLogger{w: f}, nil
+}
+
+func (l *auditLogger) Log(entry auditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(data)
+	return err
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	v1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+)
+
+func TestValidatePodAuditLog(t *testing.T) {
+	server := newTestServer(policy.Policy{
+		GPUPrefixes:              []string{"nvidia.com"},
+		MaxGPUsPerPod:            1,
+		MaxMIGDevicesPerPod:      -1,
+		MaxGPUsPerNamespace:      -1,
+		MaxGPUsAnnotationCeiling: -1,
+	}, testNamespace("default", nil))
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit, err := newAuditLogger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.audit = audit
+
+	requests := []struct {
+		uid    string
+		gpus   int64
+		dryRun bool
+	}{
+		{uid: "allowed", gpus: 1},
+		{uid: "denied", gpus: 2},
+		{uid: "dry-run", gpus: 2, dryRun: true},
+	}
+	for _, request := range requests {
+		raw, err := json.Marshal(corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "train-" + request.uid},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", request.gpus))}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := json.Marshal(v1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Request: &v1.AdmissionRequest{
+				UID:       types.UID("uid-" + request.uid),
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+				Namespace: "default",
+				Operation: v1.Create,
+				UserInfo:  authenticationv1.UserInfo{Username: "alice", Groups: []string{"ml-team", "system:authenticated"}},
+				Object:    runtime.RawExtension{Raw: raw},
+				DryRun:    ptr.To(request.dryRun),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.validatePod(httptest.NewRecorder(), admissionRequest("/validate", strings.NewReader(string(body))))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid audit line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the denial is audited; allowed pods and dry runs are not.
+	if len(entries) != 1 {
+		t.Fatalf("audit log has %d entries, want 1: %+v", len(entries), entries)
+	}
+	entry := entries[0]
+	if entry.UID != "uid-denied" || entry.User != "alice" || entry.Namespace != "default" || entry.Pod != "train-denied" ||
+		entry.Policy != "default" || entry.Reason != policy.ReasonMaxGPUsExceeded {
+		t.Errorf("entry = %+v, want the denial of train-denied by alice", entry)
+	}
+	if len(entry.Groups) != 2 || entry.Groups[0] != "ml-team" {
+		t.Errorf("groups = %q, want the requesting user's groups", entry.Groups)
+	}
+	if entry.Message == "" || entry.Timestamp.IsZero() {
+		t.Errorf("entry = %+v, want a message and timestamp", entry)
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// clientAuthConfig makes tlsConfig require a client certificate signed by the
+// CAs in caFile, such as the API server's --proxy-client-cert-file. When names
+// is not empty the certificate's common name or one of its DNS names must also
+// be listed. Connections that fail either check are closed during the
+// handshake and never reach a handler.
+func clientAuthConfig(tlsConfig *tls.Config, caFile string, names []string) error {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return fmt.Errorf("read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificates found in client CA file %s", caFile)
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	if len(names) > 0 {
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyClientName(state, names)
+		}
+	}
+	return nil
+}
+
+func verifyClientName(state tls.ConnectionState, names []string) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("no client certificate")
+	}
+	cert := state.PeerCertificates[0]
+	if slices.Contains(names, cert.Subject.CommonName) {
+		return nil
+	}
+	for _, name := range cert.DNSNames {
+		if slices.Contains(names, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("client certificate %q is not in the allowed client names", cert.Subject.CommonName)
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// The Server is the policy.Cluster of its own evaluator. Calls that reach the
+// API server are subject to the --api-qps rate limit and retried on transient
+// errors.
+
+// GetNamespace returns the namespace from the informer cache or the TTL cache.
+func (s *Server) GetNamespace(ctx context.Context, name string) (*corev1.Namespace, error) {
+	return s.namespaces.Get(ctx, name)
+}
+
+// ListPods returns the pods in the namespace from the informer cache when one
+// is running, otherwise from the API server.
+func (s *Server) ListPods(ctx context.Context, namespace string) ([]*corev1.Pod, error) {
+	if s.podLister != nil {
+		return s.podLister.Pods(namespace).List(labels.Everything())
+	}
+	var pods *corev1.PodList
+	err := s.callAPI(ctx, "list pods", func() (err error) {
+		pods, err = s.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*corev1.Pod, 0, len(pods.Items))
+	for i := range pods.Items {
+		result = append(result, &pods.Items[i])
+	}
+	return result, nil
+}
+
+// ListResourceQuotas returns the ResourceQuotas in the namespace.
+func (s *Server) ListResourceQuotas(ctx context.Context, namespace string) ([]corev1.ResourceQuota, error) {
+	var quotas *corev1.ResourceQuotaList
+	err := s.callAPI(ctx, "list resource quotas", func() (err error) {
+		quotas, err = s.clientset.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quotas.Items, nil
+}
+
+// ListLimitRanges returns the LimitRanges in the namespace.
+func (s *Server) ListLimitRanges(ctx context.Context, namespace string) ([]corev1.LimitRange, error) {
+	var limitRanges *corev1.LimitRangeList
+	err := s.callAPI(ctx, "list limit ranges", func() (err error) {
+		limitRanges, err = s.clientset.CoreV1().LimitRanges(namespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return limitRanges.Items, nil
+}
+
+// GetPriorityClass returns the named PriorityClass.
+func (s *Server) GetPriorityClass(ctx context.Context, name string) (*schedulingv1.PriorityClass, error) {
+	var priorityClass *schedulingv1.PriorityClass
+	err := s.callAPI(ctx, "get priority class", func() (err error) {
+		priorityClass, err = s.clientset.SchedulingV1().PriorityClasses().Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	return priorityClass, err
+}
+
+// ListNodes returns all nodes from the informer cache when one is running,
+// otherwise from the API server.
+func (s *Server) ListNodes(ctx context.Context) ([]*corev1.Node, error) {
+	if s.nodeLister != nil {
+		return s.nodeLister.List(labels.Everything())
+	}
+	var nodes *corev1.NodeList
+	err := s.callAPI(ctx, "list nodes", func() (err error) {
+		nodes, err = s.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*corev1.Node, 0, len(nodes.Items))
+	for i := range nodes.Items {
+		result = append(result, &nodes.Items[i])
+	}
+	return result, nil
+}
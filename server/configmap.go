@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// PolicyConfigMapKey is the ConfigMap data key holding the policy, in the same
+// format as the policy file.
+const PolicyConfigMapKey = "policy.yaml"
+
+// parseConfigMapRef splits a namespace/name reference.
+func parseConfigMapRef(ref string) (namespace, name string, err error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("invalid policy ConfigMap %q, expected namespace/name", ref)
+	}
+	return namespace, name, nil
+}
+
+// policyFromConfigMap parses the policy stored in cm on top of defaults.
+func policyFromConfigMap(cm *corev1.ConfigMap, defaults policy.Policy) (*policy.Policy, error) {
+	data, ok := cm.Data[PolicyConfigMapKey]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %s/%s has no %s key", cm.Namespace, cm.Name, PolicyConfigMapKey)
+	}
+	return policy.Parse([]byte(data), defaults)
+}
+
+// loadPolicyConfigMap reads the policy from the ConfigMap once, so that a
+// missing or invalid policy stops the webhook from starting.
+func (s *Server) loadPolicyConfigMap(ctx context.Context, namespace, name string, defaults policy.Policy) (*policy.Policy, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.apiTimeout)
+	defer cancel()
+	cm, err := s.clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return policyFromConfigMap(cm, defaults)
+}
+
+// watchPolicyConfigMap reloads the policy whenever the ConfigMap changes. An
+// update that fails to parse or validate is logged and the last good policy
+// stays in effect, as does the policy of a deleted ConfigMap.
+func (s *Server) watchPolicyConfigMap(ctx context.Context, namespace, name string, defaults policy.Policy) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(s.clientset, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}))
+	reload := func(obj any) {
+		cm, ok := obj.(*corev1.ConfigMap)
+		if !ok {
+			return
+		}
+		p, err := policyFromConfigMap(cm, defaults)
+		if err != nil {
+			klog.Errorf("Failed to reload policy from ConfigMap %s/%s, keeping previous policy: %v", namespace, name, err)
+			return
+		}
+		s.setPolicy(p)
+		klog.Infof("Reloaded policy from ConfigMap %s/%s (resource version %s)", namespace, name, cm.ResourceVersion)
+	}
+	_, err := factory.Core().V1().ConfigMaps().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: reload,
+		UpdateFunc: func(oldObj, newObj any) {
+			reload(newObj)
+		},
+		DeleteFunc: func(any) {
+			klog.Warningf("Policy ConfigMap %s/%s was deleted, keeping previous policy", namespace, name)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	factory.Start(ctx.Done())
+	return nil
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestWatchPolicyConfigMap(t *testing.T) {
+	defaults := policy.Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 1, MaxGPUsPerNamespace: -1, Mode: policy.ModeEnforce}
+	policyConfigMap := func(data string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "gpu-system", Name: "gpu-policy"},
+			Data:       map[string]string{PolicyConfigMapKey: data},
+		}
+	}
+	server := newTestServer(defaults, policyConfigMap("maxGPUsPerPod: 2\n"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p, err := server.loadPolicyConfigMap(ctx, "gpu-system", "gpu-policy", defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.setPolicy(p)
+	if err := server.watchPolicyConfigMap(ctx, "gpu-system", "gpu-policy", defaults); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForMaxGPUs := func(want int64) {
+		t.Helper()
+		err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true, func(context.Context) (bool, error) {
+			return server.currentPolicy().MaxGPUsPerPod == want, nil
+		})
+		if err != nil {
+			t.Fatalf("MaxGPUsPerPod = %d, want %d", server.currentPolicy().MaxGPUsPerPod, want)
+		}
+	}
+	waitForMaxGPUs(2)
+
+	configMaps := server.clientset.CoreV1().ConfigMaps("gpu-system")
+	if _, err := configMaps.Update(ctx, policyConfigMap("maxGPUsPerPod: 4\n"), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForMaxGPUs(4)
+
+	// An invalid update keeps the last good policy.
+	if _, err := configMaps.Update(ctx, policyConfigMap("maxGPUsPerPod: lots\n"), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := configMaps.Update(ctx, policyConfigMap("maxGPUsPerPod: 4\nunknownField: true\n"), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	waitForMaxGPUs(4)
+}
+
+func TestParseConfigMapRef(t *testing.T) {
+	for _, ref := range []string{"gpu-policy", "/gpu-policy", "gpu-system/", "a/b/c"} {
+		if _, _, err := parseConfigMapRef(ref); err == nil {
+			t.Errorf("parseConfigMapRef(%q) succeeded, want an error", ref)
+		}
+	}
+	namespace, name, err := parseConfigMapRef("gpu-system/gpu-policy")
+	if err != nil || namespace != "gpu-system" || name != "gpu-policy" {
+		t.Errorf("parseConfigMapRef = %q, %q, %v", namespace, name, err)
+	}
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// containerReport describes the GPU resources requested by one container.
+type containerReport struct {
+	Name string            `json:"name"`
+	Type string            `json:"type"`
+	GPUs map[string]string `json:"gpus"`
+}
+
+// evaluationReport explains how the policy evaluated a pod.
+type evaluationReport struct {
+	Namespace     string            `json:"namespace"`
+	Pod           string            `json:"pod"`
+	Containers    []containerReport `json:"containers"`
+	TotalGPUs     int64             `json:"totalGPUs"`
+	MIGDevices    int64             `json:"migDevices"`
+	TimeSliced    int64             `json:"timeSlicedReplicas"`
+	MaxGPUsPerPod int64             `json:"maxGPUsPerPod"`
+	// Exemptions lists the reason of the exemption that let the pod bypass
+	// the policy, and Exemption the rule behind it.
+	Exemptions []string `json:"exemptions"`
+	Exemption  string   `json:"exemption,omitempty"`
+	Allowed    bool     `json:"allowed"`
+	Reason     string   `json:"reason"`
+	Message    string   `json:"message,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+// policyReport is served on /debug/policy.
+type policyReport struct {
+	Hash   string         `json:"hash"`
+	Policy *policy.Policy `json:"policy"`
+	// Policies are the named policies from the config file, sorted by path.
+	Policies []namedPolicyReport `json:"policies,omitempty"`
+	// DelegateURL is Config.DelegateURL with any credentials redacted.
+	DelegateURL string `json:"delegateURL,omitempty"`
+}
+
+type namedPolicyReport struct {
+	Name   string         `json:"name"`
+	Path   string         `json:"path"`
+	Policy *policy.Policy `json:"policy"`
+}
+
+// debugPolicy serves the policy currently enforced, after flags, the policy
+// file and any reloads have been applied.
+func (s *Server) debugPolicy(w http.ResponseWriter, r *http.Request) {
+	p := s.currentPolicy()
+	report := policyReport{
+		Hash:        p.Hash(),
+		Policy:      p,
+		DelegateURL: redactURL(s.config.DelegateURL),
+	}
+	for path, named := range p.Routes() {
+		report.Policies = append(report.Policies, namedPolicyReport{Name: named.DisplayName(), Path: path, Policy: named})
+	}
+	sort.Slice(report.Policies, func(i, j int) bool { return report.Policies[i].Path < report.Policies[j].Path })
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// redactURL hides the password and query values of rawURL, where tokens for
+// a delegate are usually passed. A URL that does not parse is hidden
+// entirely.
+func redactURL(rawURL string) string {
+	if rawURL == "" {
+		return ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "xxxxx"
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query[key] = []string{"xxxxx"}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
+
+// debugEvaluate evaluates a posted AdmissionReview and returns an explanation
+// of the decision. It does not record metrics or audit entries.
+func (s *Server) debugEvaluate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed, "only POST is supported")
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxRequestBytes))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrReadBody, fmt.Sprintf("failed to read body: %v", err))
+		return
+	}
+	ar, _, err := s.decodeAdmissionReview(body)
+	if err != nil {
+		code := ErrDecode
+		if errors.Is(err, errNilRequest) {
+			code = ErrNilRequest
+		}
+		writeError(w, http.StatusBadRequest, code, fmt.Sprintf("failed to decode body: %v", err))
+		return
+	}
+	pod := corev1.Pod{}
+	if err := json.Unmarshal(ar.Request.Object.Raw, &pod); err != nil {
+		writeError(w, http.StatusBadRequest, ErrUnmarshal, fmt.Sprintf("failed to unmarshal pod: %v", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.apiTimeout)
+	defer cancel()
+	report := s.explain(ctx, &pod, ar.Request.Namespace)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (s *Server) explain(ctx context.Context, pod *corev1.Pod, namespace string) *evaluationReport {
+	p := s.currentPolicy().ForNamespace(namespace)
+	report := &evaluationReport{
+		Namespace:     namespace,
+		Pod:           policy.PodDisplayName(pod),
+		Containers:    []containerReport{},
+		TotalGPUs:     p.PodGPURequests(pod),
+		MIGDevices:    p.PodMIGRequests(pod),
+		TimeSliced:    p.PodTimeSlicedReplicas(pod, namespace),
+		MaxGPUsPerPod: p.MaxGPUsFor(pod, namespace),
+		Exemptions:    []string{},
+	}
+
+	addContainers := func(containerType string, containers []corev1.Container) {
+		for _, container := range containers {
+			gpus := make(map[string]string)
+			for resourceName, quantity := range policy.EffectiveRequests(container.Resources) {
+				if p.IsGPUResource(resourceName) {
+					gpus[string(resourceName)] = quantity.String()
+				}
+			}
+			if len(gpus) > 0 {
+				report.Containers = append(report.Containers, containerReport{Name: container.Name, Type: containerType, GPUs: gpus})
+			}
+		}
+	}
+	addContainers("container", pod.Spec.Containers)
+	addContainers("init", pod.Spec.InitContainers)
+	ephemeral := make([]corev1.Container, 0, len(pod.Spec.EphemeralContainers))
+	for _, container := range pod.Spec.EphemeralContainers {
+		ephemeral = append(ephemeral, corev1.Container(container.EphemeralContainerCommon))
+	}
+	addContainers("ephemeral", ephemeral)
+
+	decision := s.evaluator.Evaluate(ctx, p, pod, namespace)
+	report.Allowed = decision.Allowed
+	report.Reason = decision.Reason
+	report.Message = decision.Message
+	report.Warnings = decision.Warnings
+	if decision.Exemption != "" {
+		report.Exemptions = append(report.Exemptions, decision.Reason)
+		report.Exemption = decision.Exemption
+	}
+	return report
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	v1 "k8s.io/api/admission/v1"
+	"k8s.io/klog/v2"
+)
+
+// DefaultDelegateTimeout bounds a call to the --delegate-url service.
+const DefaultDelegateTimeout = 2 * time.Second
+
+// delegate forwards AdmissionReviews the local policy allowed to a
+// downstream service, such as a budgeting system, that can veto them.
+type delegate struct {
+	url     string
+	client  *http.Client
+	timeout time.Duration
+}
+
+func newDelegate(rawURL string, timeout time.Duration) (*delegate, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid delegate URL %q, must be an http or https URL", rawURL)
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("invalid delegate timeout %s, must be positive", timeout)
+	}
+	return &delegate{url: rawURL, client: &http.Client{}, timeout: timeout}, nil
+}
+
+// review posts the AdmissionReview and returns the delegate's response.
+func (d *delegate) review(ctx context.Context, ar *v1.AdmissionReview) (*v1.AdmissionResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	body, err := json.Marshal(v1.AdmissionReview{TypeMeta: ar.TypeMeta, Request: ar.Request})
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := d.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(response.Body, DefaultMaxRequestBytes))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("delegate returned %s", response.Status)
+	}
+	var review v1.AdmissionReview
+	if err := json.Unmarshal(data, &review); err != nil {
+		return nil, fmt.Errorf("failed to decode delegate response: %w", err)
+	}
+	if review.Response == nil {
+		return nil, fmt.Errorf("delegate response has no response")
+	}
+	if review.Response.UID != ar.Request.UID {
+		return nil, fmt.Errorf("delegate response has UID %q, want %q", review.Response.UID, ar.Request.UID)
+	}
+	return review.Response, nil
+}
+
+// evaluateDelegate asks the delegate to confirm a decision the local policy
+// allowed. An unreachable or misbehaving delegate is handled like a failed
+// lookup, according to --fail-open.
+func (s *Server) evaluateDelegate(ctx context.Context, ar *v1.AdmissionReview, decision policy.Decision) policy.Decision {
+	response, err := s.delegate.review(ctx, ar)
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Failed to call delegate", "url", s.delegate.url)
+		if s.config.FailOpen {
+			decision.Reason = policy.ReasonDelegateFailed
+			return decision
+		}
+		return policy.Decision{Reason: policy.ReasonDelegateFailed, Message: fmt.Sprintf("unable to reach GPU policy delegate: %v", err)}
+	}
+	decision.Warnings = append(decision.Warnings, response.Warnings...)
+	if response.Allowed {
+		return decision
+	}
+	message := "denied by the GPU policy delegate"
+	if response.Result != nil && response.Result.Message != "" {
+		message = response.Result.Message
+	}
+	return policy.Decision{Reason: policy.ReasonDelegateDenied, Message: message, Warnings: decision.Warnings}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	v1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateDelegate(t *testing.T) {
+	respond := func(response v1.AdmissionResponse) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var review v1.AdmissionReview
+			if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+				http.Error(w, "bad review", http.StatusBadRequest)
+				return
+			}
+			response.UID = review.Request.UID
+			_ = json.NewEncoder(w).Encode(v1.AdmissionReview{TypeMeta: review.TypeMeta, Response: &response})
+		}
+	}
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		failOpen    bool
+		wantAllowed bool
+		wantReason  string
+		wantMessage string
+		wantWarning string
+	}{
+		{name: "allowed", handler: respond(v1.AdmissionResponse{Allowed: true, Warnings: []string{"budget nearly spent"}}), wantAllowed: true, wantReason: policy.ReasonWithinLimit, wantWarning: "budget nearly spent"},
+		{
+			name:        "denied",
+			handler:     respond(v1.AdmissionResponse{Result: &metav1.Status{Message: "team budget exhausted"}}),
+			wantReason:  policy.ReasonDelegateDenied,
+			wantMessage: "team budget exhausted",
+		},
+		{name: "denied without message", handler: respond(v1.AdmissionResponse{}), wantReason: policy.ReasonDelegateDenied, wantMessage: "denied by the GPU policy delegate"},
+		{
+			name:        "error fails closed",
+			handler:     func(w http.ResponseWriter, r *http.Request) { http.Error(w, "boom", http.StatusInternalServerError) },
+			wantReason:  policy.ReasonDelegateFailed,
+			wantMessage: "unable to reach GPU policy delegate: delegate returned 500 Internal Server Error",
+		},
+		{
+			name:        "error fails open",
+			handler:     func(w http.ResponseWriter, r *http.Request) { http.Error(w, "boom", http.StatusInternalServerError) },
+			failOpen:    true,
+			wantAllowed: true,
+			wantReason:  policy.ReasonDelegateFailed,
+		},
+		{
+			name: "timeout",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				// The request is only canceled once its body has been read.
+				_, _ = io.Copy(io.Discard, r.Body)
+				<-r.Context().Done()
+			},
+			wantReason:  policy.ReasonDelegateFailed,
+			wantMessage: "unable to reach GPU policy delegate: Post \"URL\": context deadline exceeded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := httptest.NewServer(tt.handler)
+			defer backend.Close()
+			delegate, err := newDelegate(backend.URL, 100*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := newTestServer(policy.Policy{})
+			server.delegate = delegate
+			server.config.FailOpen = tt.failOpen
+
+			ar := &v1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request:  &v1.AdmissionRequest{UID: "abc", Namespace: "default"},
+			}
+			decision := server.evaluateDelegate(context.Background(), ar, policy.Decision{Allowed: true, Reason: policy.ReasonWithinLimit})
+			wantMessage := strings.ReplaceAll(tt.wantMessage, "URL", backend.URL)
+			if decision.Allowed != tt.wantAllowed || decision.Reason != tt.wantReason || decision.Message != wantMessage {
+				t.Errorf("got allowed=%v reason=%s message=%q, want allowed=%v reason=%s message=%q",
+					decision.Allowed, decision.Reason, decision.Message, tt.wantAllowed, tt.wantReason, wantMessage)
+			}
+			if tt.wantWarning != "" && (len(decision.Warnings) != 1 || decision.Warnings[0] != tt.wantWarning) {
+				t.Errorf("warnings = %q, want [%q]", decision.Warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestNewDelegateRejectsInvalidConfig(t *testing.T) {
+	for _, tt := range []struct {
+		url     string
+		timeout time.Duration
+	}{
+		{url: "budget.example.com", timeout: time.Second},
+		{url: "ftp://budget.example.com", timeout: time.Second},
+		{url: "https://budget.example.com", timeout: 0},
+	} {
+		if _, err := newDelegate(tt.url, tt.timeout); err == nil {
+			t.Errorf("newDelegate(%q, %s) succeeded, want an error", tt.url, tt.timeout)
+		}
+	}
+}
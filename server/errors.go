@@ -0,0 +1,44 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ErrorCode identifies why a request was rejected before its pod could be
+// evaluated, so that monitoring can tell client misconfiguration apart from
+// webhook bugs.
+type ErrorCode string
+
+// Error codes returned in errorBody and, for requests answered with an
+// AdmissionReview, as the type of the response's status cause.
+const (
+	ErrMethodNotAllowed     ErrorCode = "ERR_METHOD_NOT_ALLOWED"
+	ErrUnsupportedMediaType ErrorCode = "ERR_UNSUPPORTED_MEDIA_TYPE"
+	ErrTooManyRequests      ErrorCode = "ERR_TOO_MANY_REQUESTS"
+	ErrBodyTooLarge         ErrorCode = "ERR_BODY_TOO_LARGE"
+	ErrReadBody             ErrorCode = "ERR_READ_BODY"
+	ErrEmptyBody            ErrorCode = "ERR_EMPTY_BODY"
+	// ErrDecode means the body is not an AdmissionReview.
+	ErrDecode ErrorCode = "ERR_DECODE"
+	// ErrNilRequest means the AdmissionReview has no request.
+	ErrNilRequest ErrorCode = "ERR_NIL_REQUEST"
+	// ErrUnmarshal means the request's object is not the expected kind.
+	ErrUnmarshal ErrorCode = "ERR_UNMARSHAL"
+	// ErrUserBudget means /debug/users could not read the user budgets.
+	ErrUserBudget ErrorCode = "ERR_USER_BUDGET"
+)
+
+// errorBody is the JSON body of requests rejected with an HTTP error status.
+type errorBody struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// writeError rejects a request with status and a JSON errorBody.
+func writeError(w http.ResponseWriter, status int, code ErrorCode, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorBody{Code: code, Message: message})
+}
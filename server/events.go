@@ -0,0 +1,77 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const eventReasonGPUPolicyDenied = "GPUPolicyDenied"
+
+// eventEmitter records Warning events for denied pods. The denied pod never
+// exists, so the event is attached to its controller when it has one and to
+// the namespace otherwise. Identical events for the same object are only
+// emitted once per throttle interval.
+type eventEmitter struct {
+	recorder record.EventRecorder
+	throttle time.Duration
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func newEventEmitter(clientset kubernetes.Interface, throttle time.Duration) *eventEmitter {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	return &eventEmitter{
+		recorder: broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "gpu-policy-webhook"}),
+		throttle: throttle,
+		last:     make(map[string]time.Time),
+	}
+}
+
+func (e *eventEmitter) Denied(pod *corev1.Pod, namespace, message string) {
+	ref := eventTarget(pod, namespace)
+	key := ref.Kind + "/" + ref.Namespace + "/" + ref.Name + "/" + message
+
+	now := time.Now()
+	e.mu.Lock()
+	if last, ok := e.last[key]; ok && now.Sub(last) < e.throttle {
+		e.mu.Unlock()
+		return
+	}
+	e.last[key] = now
+	for k, t := range e.last {
+		if now.Sub(t) >= e.throttle {
+			delete(e.last, k)
+		}
+	}
+	e.mu.Unlock()
+
+	e.recorder.Event(ref, corev1.EventTypeWarning, eventReasonGPUPolicyDenied, message)
+}
+
+// eventTarget returns the object a denial event is attached to.
+func eventTarget(pod *corev1.Pod, namespace string) *corev1.ObjectReference {
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		return &corev1.ObjectReference{
+			APIVersion: owner.APIVersion,
+			Kind:       owner.Kind,
+			Name:       owner.Name,
+			Namespace:  namespace,
+			UID:        owner.UID,
+		}
+	}
+	return &corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Namespace",
+		Name:       namespace,
+		Namespace:  namespace,
+	}
+}
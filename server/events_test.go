@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+)
+
+func TestEventEmitterThrottlesDuplicates(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	e := &eventEmitter{recorder: recorder, throttle: time.Hour, last: make(map[string]time.Time)}
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "trainer-abc",
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: "batch/v1", Kind: "Job", Name: "trainer", Controller: ptr.To(true),
+		}},
+	}}
+	e.Denied(pod, "team-a", "too many GPUs")
+	e.Denied(pod, "team-a", "too many GPUs")
+	e.Denied(&corev1.Pod{}, "team-a", "too many GPUs")
+
+	if got := len(recorder.Events); got != 2 {
+		t.Fatalf("got %d events, want 2", got)
+	}
+}
+
+func TestEventTarget(t *testing.T) {
+	ref := eventTarget(&corev1.Pod{}, "team-a")
+	if ref.Kind != "Namespace" || ref.Name != "team-a" {
+		t.Errorf("got %s/%s, want Namespace/team-a", ref.Kind, ref.Name)
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+)
+
+var update = flag.Bool("update", false, "Regenerate the golden files in testdata/admission")
+
+// TestAdmissionFixtures replays every AdmissionReview in testdata/admission
+// against /validate and compares the response with the .golden file next to
+// it. Run with -update to regenerate the golden files after an intended
+// change, and review the diff.
+func TestAdmissionFixtures(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "admission", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures found in testdata/admission")
+	}
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".json")
+		t.Run(name, func(t *testing.T) {
+			body, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := newTestServer(policy.Policy{
+				GPUPrefixes:              []string{"nvidia.com"},
+				MaxGPUsPerPod:            2,
+				MaxGPUsAnnotationCeiling: -1,
+				MaxMIGDevicesPerPod:      -1,
+				MaxGPUsPerNamespace:      -1,
+			}, testNamespace("team", nil), testNamespace("ml", map[string]string{"gpu-policy/allowed": "true"}))
+			server.audit = &auditLogger{w: io.Discard}
+
+			recorder := httptest.NewRecorder()
+			server.validatePod(recorder, admissionRequest("/validate", bytes.NewReader(body)))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body)
+			}
+			var got bytes.Buffer
+			if err := json.Indent(&got, recorder.Body.Bytes(), "", "  "); err != nil {
+				t.Fatalf("response is not JSON: %v", err)
+			}
+			got.WriteByte('\n')
+
+			golden := strings.TrimSuffix(fixture, ".json") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v; run go test ./server -run TestAdmissionFixtures -update to create it", err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("response differs from %s:\ngot:\n%s\nwant:\n%s", golden, got.Bytes(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net/http"
+
+	"k8s.io/klog/v2"
+)
+
+// healthz reports the process as live once the webhook listener is open.
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	if !s.listening.Load() {
+		http.Error(w, "webhook server is not listening", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+// readyz reports ready only once the clientset can reach the API server, or
+// right away when the webhook runs without a clientset. A policy that failed
+// its startup self-test is never ready.
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	if !s.listening.Load() {
+		http.Error(w, "webhook server is not listening", http.StatusServiceUnavailable)
+		return
+	}
+	if s.selfTestErr != nil {
+		http.Error(w, "policy self-test failed: "+s.selfTestErr.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if s.clientset == nil {
+		w.Write([]byte("ok"))
+		return
+	}
+	if s.informersSynced != nil && !s.informersSynced() {
+		http.Error(w, "informer caches are not synced", http.StatusServiceUnavailable)
+		return
+	}
+	if _, err := s.clientset.Discovery().ServerVersion(); err != nil {
+		klog.Errorf("Readiness check failed: %v", err)
+		http.Error(w, "unable to reach the API server", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
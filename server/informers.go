@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// namespaceGetter looks up Namespace objects for the enforcement path.
+type namespaceGetter interface {
+	Get(ctx context.Context, name string) (*corev1.Namespace, error)
+}
+
+// namespaceListerGetter serves namespaces from a shared informer cache. A
+// namespace created moments ago may not have reached the cache yet, so a miss
+// falls back to a direct GET. Lookups are counted in namespaceCacheLookups
+// like those of namespaceCache.
+type namespaceListerGetter struct {
+	lister    corelisters.NamespaceLister
+	clientset kubernetes.Interface
+	limiter   *rate.Limiter
+}
+
+func (g *namespaceListerGetter) Get(ctx context.Context, name string) (*corev1.Namespace, error) {
+	ns, err := g.lister.Get(name)
+	if err == nil {
+		namespaceCacheLookups.WithLabelValues(cacheHit).Inc()
+		return ns, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	namespaceCacheLookups.WithLabelValues(cacheMiss).Inc()
+	klog.FromContext(ctx).V(4).Info("Namespace not in informer cache, fetching it", "namespace", name)
+	err = callAPI(ctx, g.limiter, "get namespace", func() (err error) {
+		ns, err = g.clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	return ns, err
+}
+
+// listsPods reports whether admission lists the pods of a namespace, for a
+// namespace quota of p or one of its named policies or for
+// --check-resource-quota, so that they are worth watching.
+func listsPods(config Config, p *policy.Policy) bool {
+	if config.CheckResourceQuota || p.HasNamespaceQuota() {
+		return true
+	}
+	for _, named := range p.Routes() {
+		if named.HasNamespaceQuota() {
+			return true
+		}
+	}
+	return false
+}
+
+// startInformers starts the shared informers backing namespace lookups and,
+// when withPods is set, the per-namespace GPU usage and, when withNodes is
+// set, the node capacity check. Without a pod informer, pods are listed from
+// the API server, as when a reloaded policy adds the first namespace quota.
+// The caches are synced in the background; /readyz reports not ready until
+// they are. Changes to a namespace invalidate its resolved policies.
+func (s *Server) startInformers(ctx context.Context, withPods, withNodes bool) error {
+	factory := informers.NewSharedInformerFactory(s.clientset, 0)
+
+	namespaceInformer := factory.Core().V1().Namespaces()
+	if s.policies != nil {
+		invalidate := func(obj any) {
+			if name, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+				s.policies.invalidate(name)
+			}
+		}
+		_, err := namespaceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: invalidate,
+			UpdateFunc: func(oldObj, newObj any) {
+				invalidate(newObj)
+			},
+			DeleteFunc: invalidate,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	s.namespaces = &namespaceListerGetter{
+		lister:    namespaceInformer.Lister(),
+		clientset: s.clientset,
+		limiter:   s.apiLimiter,
+	}
+	synced := []cache.InformerSynced{namespaceInformer.Informer().HasSynced}
+
+	if withPods {
+		podInformer := factory.Core().V1().Pods()
+		s.podLister = podInformer.Lister()
+		synced = append(synced, podInformer.Informer().HasSynced)
+	}
+	if withNodes {
+		nodeInformer := factory.Core().V1().Nodes()
+		s.nodeLister = nodeInformer.Lister()
+		synced = append(synced, nodeInformer.Informer().HasSynced)
+	}
+
+	s.informersSynced = func() bool {
+		for _, hasSynced := range synced {
+			if !hasSynced() {
+				return false
+			}
+		}
+		return true
+	}
+	factory.Start(ctx.Done())
+	return nil
+}
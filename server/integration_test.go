@@ -0,0 +1,189 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// TestIntegration runs the webhook behind a real API server. It needs the
+// envtest binaries, see KUBEBUILDER_ASSETS in the README.
+func TestIntegration(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set")
+	}
+
+	path := "/validate"
+	failurePolicy := admissionregistrationv1.Fail
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	testEnv := &envtest.Environment{
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			ValidatingWebhooks: []*admissionregistrationv1.ValidatingWebhookConfiguration{{
+				ObjectMeta: metav1.ObjectMeta{Name: "gpu-policy-webhook"},
+				Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+					Name: "gpu-policy.mayooot.github.io",
+					ClientConfig: admissionregistrationv1.WebhookClientConfig{
+						Service: &admissionregistrationv1.ServiceReference{Name: "gpu-policy-webhook", Namespace: "default", Path: &path},
+					},
+					Rules: []admissionregistrationv1.RuleWithOperations{{
+						Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+						Rule: admissionregistrationv1.Rule{
+							APIGroups:   []string{""},
+							APIVersions: []string{"v1"},
+							Resources:   []string{"pods"},
+						},
+					}},
+					FailurePolicy:           &failurePolicy,
+					SideEffects:             &sideEffects,
+					AdmissionReviewVersions: []string{"v1"},
+				}},
+			}},
+		},
+	}
+	restConfig, err := testEnv.Start()
+	if err != nil {
+		t.Fatalf("failed to start envtest: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := testEnv.Stop(); err != nil {
+			t.Errorf("failed to stop envtest: %v", err)
+		}
+	})
+
+	user, err := testEnv.AddUser(envtest.User{Name: "gpu-policy-webhook", Groups: []string{"system:masters"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubeconfig, err := user.KubeConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(kubeconfigPath, kubeconfig, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := policy.Policy{
+		GPUPrefixes:                 []string{"nvidia.com"},
+		MaxGPUsPerPod:               2,
+		MaxGPUsAnnotationCeiling:    -1,
+		MaxMIGDevicesPerPod:         -1,
+		MaxTimeSlicedReplicasPerPod: -1,
+		MaxGPUsPerNamespace:         -1,
+		Mode:                        policy.ModeEnforce,
+	}
+	if err := defaults.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	defaults.Prepare()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	options := testEnv.WebhookInstallOptions
+	webhook, err := New(ctx, Config{
+		BindAddress:         options.LocalServingHost,
+		Port:                options.LocalServingPort,
+		CertFile:            filepath.Join(options.LocalServingCertDir, "tls.crt"),
+		KeyFile:             filepath.Join(options.LocalServingCertDir, "tls.key"),
+		Kubeconfig:          kubeconfigPath,
+		APIQPS:              20,
+		APIBurst:            40,
+		APITimeout:          DefaultAPITimeout,
+		ShutdownGracePeriod: time.Second,
+		AuditLogPath:        filepath.Join(t.TempDir(), "audit.log"),
+		NamespaceAllowLabel: "gpu-policy/allowed=true",
+		UseInformers:        true,
+		NamespaceCacheSize:  DefaultNamespaceCacheSize,
+		OnError:             OnErrorDeny,
+		MaxRequestBytes:     DefaultMaxRequestBytes,
+	}, defaults)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	go func() {
+		if err := webhook.Run(ctx); err != nil {
+			t.Errorf("server stopped: %v", err)
+		}
+	}()
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ns := range []*corev1.Namespace{
+		testNamespace("team", nil),
+		testNamespace("ml", map[string]string{"gpu-policy/allowed": "true"}),
+	} {
+		if _, err := clientset.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		// There is no controller manager to create the default service
+		// account that pods are admitted with.
+		serviceAccount := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+		if _, err := clientset.CoreV1().ServiceAccounts(ns.Name).Create(ctx, serviceAccount, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gpuPod := func(name string, count int64) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "app", Image: "busybox", Resources: corev1.ResourceRequirements{Requests: gpus("nvidia.com/gpu", count), Limits: gpus("nvidia.com/gpu", count)}},
+			}},
+		}
+	}
+
+	// The webhook configuration is installed before the server listens, so
+	// wait for the first admission to get through.
+	err = wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, 30*time.Second, true, func(ctx context.Context) (bool, error) {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "warmup"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "busybox"}}},
+		}
+		_, err := clientset.CoreV1().Pods("team").Create(ctx, pod, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+		return err == nil, nil
+	})
+	if err != nil {
+		t.Fatalf("webhook did not become reachable: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		namespace   string
+		gpus        int64
+		wantMessage string
+	}{
+		{name: "within limit", namespace: "team", gpus: 2},
+		{name: "over limit", namespace: "team", gpus: 4, wantMessage: "pod requests 4 GPUs, exceeding the limit of 2 per pod in namespace team"},
+		{name: "over limit in opted-in namespace", namespace: "ml", gpus: 4},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := clientset.CoreV1().Pods(tt.namespace).Create(ctx, gpuPod(fmt.Sprintf("pod-%d", i), tt.gpus), metav1.CreateOptions{})
+			if tt.wantMessage == "" {
+				if err != nil {
+					t.Fatalf("expected pod to be allowed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantMessage) {
+				t.Fatalf("error = %v, want a denial containing %q", err, tt.wantMessage)
+			}
+		})
+	}
+}
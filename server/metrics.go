@@ -0,0 +1,115 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	decisionAllowed = "allowed"
+	decisionDenied  = "denied"
+	decisionWarned  = "warned"
+)
+
+// Results of a cache lookup.
+const (
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
+// reasonDecodeError is reported for requests that could not be decoded and so
+// never reached the policy.
+const reasonDecodeError = "decode_error"
+
+var (
+	admissionTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gpu_webhook_admission_total",
+			Help: "Total number of admission decisions made by the webhook. resources is the comma-separated set of GPU resources the pod requests.",
+		},
+		[]string{"decision", "namespace", "reason", "resources", "dry_run"},
+	)
+	throttledTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "gpu_webhook_throttled_requests_total",
+			Help: "Total number of admission requests rejected with 429 by the concurrency limit.",
+		},
+	)
+	deniedGPUsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gpu_webhook_denied_gpus_total",
+			Help: "Total quantity of GPU resources requested by denied pods.",
+		},
+		[]string{"namespace", "resource"},
+	)
+	exemptionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gpu_webhook_exemptions_total",
+			Help: "Total number of GPU pods allowed by an exemption rather than the policy, by exemption reason.",
+		},
+		[]string{"reason"},
+	)
+	namespaceCacheLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gpu_webhook_namespace_cache_lookups_total",
+			Help: "Namespace lookups served by the namespace cache or, with --use-informers, the namespace informer, by hit or miss. The hit ratio is hit over the total.",
+		},
+		[]string{"result"},
+	)
+	policyCacheLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gpu_webhook_policy_cache_lookups_total",
+			Help: "Resolved namespace policy lookups served by the policy cache with --use-informers, by hit or miss. The hit ratio is hit over the total.",
+		},
+		[]string{"result"},
+	)
+	requestDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "gpu_webhook_request_duration_seconds",
+			Help:    "Time taken to handle an admission request.",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(admissionTotal, throttledTotal, deniedGPUsTotal, exemptionsTotal, namespaceCacheLookups, policyCacheLookups, requestDuration)
+}
+
+// decisionLabel describes the outcome of an admission response.
+func decisionLabel(response *v1.AdmissionResponse) string {
+	if !response.Allowed {
+		return decisionDenied
+	}
+	if len(response.Warnings) > 0 {
+		return decisionWarned
+	}
+	return decisionAllowed
+}
+
+func recordDecision(response *v1.AdmissionResponse, namespace, reason, resources string, dryRun bool) {
+	admissionTotal.WithLabelValues(decisionLabel(response), namespace, reason, resources, strconv.FormatBool(dryRun)).Inc()
+}
+
+// resourceSet joins the GPU resources requested by the pod with commas, for
+// metric labels and logs.
+func resourceSet(p *policy.Policy, pod *corev1.Pod) string {
+	resourceNames := p.GPUResources(pod)
+	names := make([]string, len(resourceNames))
+	for i, resourceName := range resourceNames {
+		names[i] = string(resourceName)
+	}
+	return strings.Join(names, ",")
+}
+
+// recordDeniedGPUs adds the GPUs requested by a denied pod to deniedGPUsTotal.
+func recordDeniedGPUs(p *policy.Policy, pod *corev1.Pod, namespace string) {
+	for resourceName, quantity := range p.PodGPURequestsByResource(pod) {
+		deniedGPUsTotal.WithLabelValues(namespace, string(resourceName)).Add(float64(quantity))
+	}
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	"k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// DecisionAnnotation is added to allowed GPU pods by /mutate when
+// --annotate-decisions is set, e.g. "3f2a9c01b7de;reason=within_limit;limit=2".
+const DecisionAnnotation = "gpu-policy/evaluated"
+
+// patchOperation is a single RFC 6902 JSON Patch operation.
+//
+// Operations that change an existing map or list are preceded by a test
+// operation asserting the value the webhook based them on. If another
+// mutating webhook changed it in between, for example on reinvocation, the API
+// server fails to apply the patch and rejects the request instead of
+// silently overwriting the other webhook's change.
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+func (s *Server) mutatePod(w http.ResponseWriter, r *http.Request) {
+	s.serveAdmission(w, r, requestPod, s.admitMutate)
+}
+
+func (s *Server) admitMutate(ctx context.Context, ar *v1.AdmissionReview, pod, _ *corev1.Pod) *v1.AdmissionResponse {
+	response := &v1.AdmissionResponse{
+		Allowed: true,
+	}
+	p := s.currentPolicy().ForNamespace(ar.Request.Namespace)
+	if _, found := p.FindGPUResource(pod); !found {
+		return response
+	}
+
+	patch := nodeSelectorPatch(pod, p.NodeSelector)
+	var tolerations []corev1.Toleration
+	for _, prefix := range p.RequestedPrefixes(pod) {
+		tolerations = append(tolerations, p.Tolerations[prefix]...)
+	}
+	patch = append(patch, tolerationsPatch(pod, tolerations)...)
+	if s.config.AnnotateDecisions {
+		patch = append(patch, s.decisionPatch(ctx, p, pod, ar.Request.Namespace)...)
+	}
+	if len(patch) == 0 {
+		return response
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Failed to marshal patch")
+		response.Allowed = false
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: fmt.Sprintf("failed to marshal patch: %v", err),
+			Reason:  metav1.StatusReasonInternalError,
+			Code:    http.StatusInternalServerError,
+		}
+		return response
+	}
+	patchType := v1.PatchTypeJSONPatch
+	response.Patch = patchBytes
+	response.PatchType = &patchType
+	return response
+}
+
+// decisionPatch records in DecisionAnnotation that the pod passed the policy,
+// and under which configuration. Pods the policy denies or warns about are left
+// alone; the validating webhook reports those.
+func (s *Server) decisionPatch(ctx context.Context, p *policy.Policy, pod *corev1.Pod, namespace string) []patchOperation {
+	decision := s.evaluator.Evaluate(ctx, p, pod, namespace)
+	if !decision.Allowed || len(decision.Warnings) > 0 {
+		return nil
+	}
+	value := fmt.Sprintf("%s;reason=%s;limit=%d", p.Hash(), decision.Reason, p.MaxGPUsFor(pod, namespace))
+	if len(pod.Annotations) == 0 {
+		return []patchOperation{{Op: "add", Path: "/metadata/annotations", Value: map[string]string{DecisionAnnotation: value}}}
+	}
+	return []patchOperation{
+		{Op: "test", Path: "/metadata/annotations", Value: pod.Annotations},
+		{Op: "add", Path: "/metadata/annotations/" + escapeJSONPointer(DecisionAnnotation), Value: value},
+	}
+}
+
+// nodeSelectorPatch returns the operations adding the selector entries whose
+// key the pod does not already have. Keys the pod sets are left alone, even
+// with a different value, so that its own placement is never overwritten.
+func nodeSelectorPatch(pod *corev1.Pod, selector map[string]string) []patchOperation {
+	if len(selector) == 0 {
+		return nil
+	}
+	if len(pod.Spec.NodeSelector) == 0 {
+		return []patchOperation{{Op: "add", Path: "/spec/nodeSelector", Value: selector}}
+	}
+
+	keys := make([]string, 0, len(selector))
+	for key := range selector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var patch []patchOperation
+	for _, key := range keys {
+		if _, ok := pod.Spec.NodeSelector[key]; ok {
+			continue
+		}
+		patch = append(patch, patchOperation{
+			Op:    "add",
+			Path:  "/spec/nodeSelector/" + escapeJSONPointer(key),
+			Value: selector[key],
+		})
+	}
+	if len(patch) == 0 {
+		return nil
+	}
+	return append([]patchOperation{{Op: "test", Path: "/spec/nodeSelector", Value: pod.Spec.NodeSelector}}, patch...)
+}
+
+// tolerationsPatch returns the operations appending the tolerations that the
+// pod does not already have with the same key, operator and value.
+func tolerationsPatch(pod *corev1.Pod, tolerations []corev1.Toleration) []patchOperation {
+	existing := append([]corev1.Toleration(nil), pod.Spec.Tolerations...)
+	var missing []corev1.Toleration
+	for _, toleration := range tolerations {
+		if hasToleration(existing, toleration) {
+			continue
+		}
+		existing = append(existing, toleration)
+		missing = append(missing, toleration)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if len(pod.Spec.Tolerations) == 0 {
+		return []patchOperation{{Op: "add", Path: "/spec/tolerations", Value: missing}}
+	}
+
+	patch := make([]patchOperation, 0, len(missing)+1)
+	patch = append(patch, patchOperation{Op: "test", Path: "/spec/tolerations", Value: pod.Spec.Tolerations})
+	for _, toleration := range missing {
+		patch = append(patch, patchOperation{Op: "add", Path: "/spec/tolerations/-", Value: toleration})
+	}
+	return patch
+}
+
+func hasToleration(tolerations []corev1.Toleration, want corev1.Toleration) bool {
+	for _, toleration := range tolerations {
+		if toleration.Key == want.Key && tolerationOperator(toleration) == tolerationOperator(want) && toleration.Value == want.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// tolerationOperator returns the operator, treating an empty one as Equal
+// as the API server does.
+func tolerationOperator(toleration corev1.Toleration) corev1.TolerationOperator {
+	if toleration.Operator == "" {
+		return corev1.TolerationOpEqual
+	}
+	return toleration.Operator
+}
+
+// escapeJSONPointer escapes a reference token as described in RFC 6901.
+func escapeJSONPointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	"k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAdmitMutateAnnotatesDecisions(t *testing.T) {
+	p := policy.Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 2, MaxMIGDevicesPerPod: -1, MaxGPUsPerNamespace: -1, Mode: policy.ModeEnforce}
+	p.Prepare()
+	server := newTestServer(p, testNamespace("default", nil))
+	server.config.AnnotateDecisions = true
+	ar := &v1.AdmissionReview{Request: &v1.AdmissionRequest{Namespace: "default"}}
+
+	tests := []struct {
+		name      string
+		pod       corev1.Pod
+		wantPatch []patchOperation
+	}{
+		{
+			name: "allowed pod without annotations",
+			pod:  corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 2))}}},
+			wantPatch: []patchOperation{{Op: "add", Path: "/metadata/annotations", Value: map[string]interface{}{
+				DecisionAnnotation: p.Hash() + ";reason=within_limit;limit=2",
+			}}},
+		},
+		{
+			name: "allowed pod with annotations",
+			pod: corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"team": "ml"}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 1))}},
+			},
+			wantPatch: []patchOperation{
+				{Op: "test", Path: "/metadata/annotations", Value: map[string]interface{}{"team": "ml"}},
+				{Op: "add", Path: "/metadata/annotations/gpu-policy~1evaluated", Value: p.Hash() + ";reason=within_limit;limit=2"},
+			},
+		},
+		{
+			name: "denied pod",
+			pod:  corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 3))}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := server.admitMutate(context.Background(), ar, &tt.pod, nil)
+			var patch []patchOperation
+			if response.Patch != nil {
+				if err := json.Unmarshal(response.Patch, &patch); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, _ := json.Marshal(patch)
+			want, _ := json.Marshal(tt.wantPatch)
+			if string(got) != string(want) {
+				t.Errorf("patch = %s, want %s", got, want)
+			}
+		})
+	}
+	if p.Hash() == "" {
+		t.Error("expected a policy hash after Prepare")
+	}
+}
+
+func TestMutatePatchesTestPriorState(t *testing.T) {
+	toleration := corev1.Toleration{Key: "nvidia.com/gpu", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		NodeSelector: map[string]string{"zone": "a"},
+		Tolerations:  []corev1.Toleration{{Key: "spot", Operator: corev1.TolerationOpExists}},
+	}}
+
+	patch := append(nodeSelectorPatch(pod, map[string]string{"gpu": "true"}), tolerationsPatch(pod, []corev1.Toleration{toleration})...)
+	want := []patchOperation{
+		{Op: "test", Path: "/spec/nodeSelector", Value: pod.Spec.NodeSelector},
+		{Op: "add", Path: "/spec/nodeSelector/gpu", Value: "true"},
+		{Op: "test", Path: "/spec/tolerations", Value: pod.Spec.Tolerations},
+		{Op: "add", Path: "/spec/tolerations/-", Value: toleration},
+	}
+	got, _ := json.Marshal(patch)
+	wantJSON, _ := json.Marshal(want)
+	if string(got) != string(wantJSON) {
+		t.Errorf("patch = %s, want %s", got, wantJSON)
+	}
+
+	// Nothing is tested when nothing changes.
+	if patch := nodeSelectorPatch(pod, map[string]string{"zone": "a"}); patch != nil {
+		t.Errorf("patch = %+v, want none", patch)
+	}
+	// A key the pod sets is not overwritten with the configured value.
+	if patch := nodeSelectorPatch(pod, map[string]string{"zone": "b"}); patch != nil {
+		t.Errorf("patch = %+v, want the pod's zone left alone", patch)
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// DefaultNamespaceCacheSize bounds the namespaces cached without informers.
+const DefaultNamespaceCacheSize = 1000
+
+type namespaceCacheEntry struct {
+	name      string
+	namespace *corev1.Namespace
+	expires   time.Time
+}
+
+// namespaceCache caches Namespace lookups for a short TTL so that every
+// admission request does not result in a GET against the API server. It holds
+// at most size namespaces and evicts the least recently used one beyond that,
+// so clusters with many namespaces do not grow it without bound.
+type namespaceCache struct {
+	clientset kubernetes.Interface
+	limiter   *rate.Limiter
+	ttl       time.Duration
+	size      int
+
+	mu sync.Mutex
+	// lru holds *namespaceCacheEntry, most recently used first.
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+func newNamespaceCache(clientset kubernetes.Interface, limiter *rate.Limiter, ttl time.Duration, size int) *namespaceCache {
+	return &namespaceCache{
+		clientset: clientset,
+		limiter:   limiter,
+		ttl:       ttl,
+		size:      size,
+		lru:       list.New(),
+		entries:   make(map[string]*list.Element),
+	}
+}
+
+func (c *namespaceCache) Get(ctx context.Context, name string) (*corev1.Namespace, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	if element, ok := c.entries[name]; ok {
+		entry := element.Value.(*namespaceCacheEntry)
+		if now.Before(entry.expires) {
+			c.lru.MoveToFront(element)
+			c.mu.Unlock()
+			namespaceCacheLookups.WithLabelValues(cacheHit).Inc()
+			return entry.namespace, nil
+		}
+	}
+	c.mu.Unlock()
+	namespaceCacheLookups.WithLabelValues(cacheMiss).Inc()
+	klog.FromContext(ctx).V(4).Info("Namespace cache miss", "namespace", name)
+
+	var ns *corev1.Namespace
+	err := callAPI(ctx, c.limiter, "get namespace", func() (err error) {
+		ns, err = c.clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &namespaceCacheEntry{name: name, namespace: ns, expires: now.Add(c.ttl)}
+	if element, ok := c.entries[name]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return ns, nil
+	}
+	c.entries[name] = c.lru.PushFront(entry)
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*namespaceCacheEntry).name)
+	}
+	return ns, nil
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNamespaceCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	clientset := fake.NewClientset(testNamespace("a", nil), testNamespace("b", nil), testNamespace("c", nil))
+	gets := make(map[string]int)
+	clientset.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		gets[action.(k8stesting.GetAction).GetName()]++
+		return false, nil, nil
+	})
+	cache := newNamespaceCache(clientset, nil, time.Minute, 2)
+
+	ctx := context.Background()
+	for _, name := range []string{"a", "b", "a", "c", "a", "b"} {
+		if _, err := cache.Get(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// c evicted b, the least recently used, and b evicted c when it came back.
+	want := map[string]int{"a": 1, "b": 2, "c": 1}
+	for name, count := range want {
+		if gets[name] != count {
+			t.Errorf("namespace %s fetched %d times, want %d", name, gets[name], count)
+		}
+	}
+}
+
+func TestNamespaceCacheRetriesTransientErrors(t *testing.T) {
+	apiBackoff.Duration = time.Millisecond
+	t.Cleanup(func() { apiBackoff.Duration = 50 * time.Millisecond })
+
+	tests := []struct {
+		name     string
+		err      error
+		failures int
+		wantGets int
+		wantErr  bool
+	}{
+		{name: "recovers", err: apierrors.NewServiceUnavailable("etcd leader election"), failures: 2, wantGets: 3},
+		{name: "exhausted", err: apierrors.NewInternalError(errors.New("boom")), failures: 10, wantGets: apiBackoff.Steps, wantErr: true},
+		{name: "not transient", err: apierrors.NewForbidden(corev1.Resource("namespaces"), "a", errors.New("rbac")), failures: 10, wantGets: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewClientset(testNamespace("a", nil))
+			gets := 0
+			clientset.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				gets++
+				if gets <= tt.failures {
+					return true, nil, tt.err
+				}
+				return false, nil, nil
+			})
+			cache := newNamespaceCache(clientset, nil, time.Minute, 2)
+
+			_, err := cache.Get(context.Background(), "a")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gets != tt.wantGets {
+				t.Errorf("namespace fetched %d times, want %d", gets, tt.wantGets)
+			}
+		})
+	}
+}
+
+func TestNamespaceListerGetterCountsLookups(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(testNamespace("cached", nil)); err != nil {
+		t.Fatal(err)
+	}
+	getter := &namespaceListerGetter{
+		lister:    corelisters.NewNamespaceLister(indexer),
+		clientset: fake.NewClientset(testNamespace("new", nil)),
+	}
+
+	hits := testutil.ToFloat64(namespaceCacheLookups.WithLabelValues(cacheHit))
+	misses := testutil.ToFloat64(namespaceCacheLookups.WithLabelValues(cacheMiss))
+	for _, name := range []string{"cached", "new", "cached"} {
+		if _, err := getter.Get(context.Background(), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := testutil.ToFloat64(namespaceCacheLookups.WithLabelValues(cacheHit)) - hits; got != 2 {
+		t.Errorf("hits = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(namespaceCacheLookups.WithLabelValues(cacheMiss)) - misses; got != 1 {
+		t.Errorf("misses = %v, want 1", got)
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+)
+
+// policyCacheKey identifies a resolved policy by the namespace's view of the
+// policy and the namespace, as named policies resolve differently.
+type policyCacheKey struct {
+	policy    *policy.Policy
+	namespace string
+}
+
+type policyCacheEntry struct {
+	key      policyCacheKey
+	resolved *policy.ResolvedPolicy
+}
+
+// policyCache is an LRU of the resolved policies of namespaces, used with
+// --use-informers. Entries do not expire: the namespace informer invalidates
+// those of a namespace when it changes, and a policy reload purges them all.
+type policyCache struct {
+	size int
+
+	mu sync.Mutex
+	// lru holds *policyCacheEntry, most recently used first.
+	lru     *list.List
+	entries map[policyCacheKey]*list.Element
+	// generation is bumped by every invalidation, so that a policy resolved
+	// from a namespace that changed meanwhile is not cached.
+	generation uint64
+}
+
+func newPolicyCache(size int) *policyCache {
+	return &policyCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[policyCacheKey]*list.Element),
+	}
+}
+
+func (c *policyCache) Get(p *policy.Policy, namespace string, resolve func() (*policy.ResolvedPolicy, error)) (*policy.ResolvedPolicy, error) {
+	key := policyCacheKey{policy: p, namespace: namespace}
+
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(element)
+		c.mu.Unlock()
+		policyCacheLookups.WithLabelValues(cacheHit).Inc()
+		return element.Value.(*policyCacheEntry).resolved, nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+	policyCacheLookups.WithLabelValues(cacheMiss).Inc()
+
+	resolved, err := resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != generation {
+		return resolved, nil
+	}
+	entry := &policyCacheEntry{key: key, resolved: resolved}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.lru.MoveToFront(element)
+		return resolved, nil
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*policyCacheEntry).key)
+	}
+	return resolved, nil
+}
+
+// invalidate drops the resolved policies of namespace.
+func (c *policyCache) invalidate(namespace string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for element := c.lru.Front(); element != nil; {
+		next := element.Next()
+		if entry := element.Value.(*policyCacheEntry); entry.key.namespace == namespace {
+			c.lru.Remove(element)
+			delete(c.entries, entry.key)
+		}
+		element = next
+	}
+}
+
+// purge drops every resolved policy.
+func (c *policyCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.lru.Init()
+	c.entries = make(map[policyCacheKey]*list.Element)
+}
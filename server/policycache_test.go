@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPolicyCache(t *testing.T) {
+	cache := newPolicyCache(2)
+	defaults, named := &policy.Policy{}, &policy.Policy{}
+	resolves := make(map[string]int)
+	get := func(p *policy.Policy, namespace string) {
+		t.Helper()
+		_, err := cache.Get(p, namespace, func() (*policy.ResolvedPolicy, error) {
+			resolves[namespace]++
+			return &policy.ResolvedPolicy{Policy: p}, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hits := testutil.ToFloat64(policyCacheLookups.WithLabelValues(cacheHit))
+	misses := testutil.ToFloat64(policyCacheLookups.WithLabelValues(cacheMiss))
+	get(defaults, "a")
+	get(defaults, "a")
+	get(named, "a")
+	if resolves["a"] != 2 {
+		t.Errorf("namespace a resolved %d times, want once per policy", resolves["a"])
+	}
+	if got := testutil.ToFloat64(policyCacheLookups.WithLabelValues(cacheHit)) - hits; got != 1 {
+		t.Errorf("hits = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(policyCacheLookups.WithLabelValues(cacheMiss)) - misses; got != 2 {
+		t.Errorf("misses = %v, want 2", got)
+	}
+
+	cache.invalidate("a")
+	get(defaults, "b")
+	get(defaults, "a")
+	get(defaults, "b")
+	if resolves["a"] != 3 || resolves["b"] != 1 {
+		t.Errorf("resolves = %v after invalidating a, want a: 3, b: 1", resolves)
+	}
+
+	// a under the named policy evicts a under the defaults, the least
+	// recently used entry.
+	get(named, "a")
+	get(defaults, "b")
+	get(defaults, "a")
+	if resolves["a"] != 5 || resolves["b"] != 1 {
+		t.Errorf("resolves = %v after an eviction, want a: 5, b: 1", resolves)
+	}
+
+	cache.purge()
+	get(defaults, "b")
+	if resolves["b"] != 2 {
+		t.Errorf("namespace b resolved %d times, want 2 after a purge", resolves["b"])
+	}
+}
+
+func TestPolicyCacheDropsInvalidatedResolve(t *testing.T) {
+	cache := newPolicyCache(2)
+	p := &policy.Policy{}
+	resolves := 0
+	resolve := func() (*policy.ResolvedPolicy, error) {
+		resolves++
+		if resolves == 1 {
+			// The namespace changes while it is resolved.
+			cache.invalidate("a")
+		}
+		return &policy.ResolvedPolicy{Policy: p}, nil
+	}
+	for range 2 {
+		if _, err := cache.Get(p, "a", resolve); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if resolves != 2 {
+		t.Errorf("resolved %d times, want the stale policy not to be cached", resolves)
+	}
+}
+
+func TestPolicyCacheFollowsNamespaceInformer(t *testing.T) {
+	server := newTestServer(policy.Policy{
+		GPUPrefixes:         []string{"nvidia.com"},
+		MaxGPUsPerPod:       -1,
+		MaxMIGDevicesPerPod: -1,
+		MaxGPUsPerNamespace: -1,
+	}, testNamespace("ml", nil))
+	server.policies = newPolicyCache(DefaultNamespaceCacheSize)
+	server.evaluator.(*policy.Evaluator).Policies = server.policies
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := server.startInformers(ctx, false, false); err != nil {
+		t.Fatal(err)
+	}
+	for !server.informersSynced() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 1))}}}
+	evaluate := func() policy.Decision {
+		return server.evaluator.Evaluate(ctx, server.currentPolicy(), pod, "ml")
+	}
+	if decision := evaluate(); decision.Allowed {
+		t.Fatalf("GPU pod allowed in a namespace without the opt-in label: %+v", decision)
+	}
+
+	ns := testNamespace("ml", map[string]string{"gpu-policy/allowed": "true"})
+	if _, err := server.clientset.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !evaluate().Allowed {
+		if time.Now().After(deadline) {
+			t.Fatal("labeling the namespace did not invalidate its cached policy within 5s")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	server.setPolicy(&policy.Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: -1})
+	if server.policies.lru.Len() != 0 {
+		t.Errorf("%d resolved policies kept after a reload", server.policies.lru.Len())
+	}
+}
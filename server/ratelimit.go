@@ -0,0 +1,43 @@
+package server
+
+import (
+	"errors"
+
+	"golang.org/x/time/rate"
+)
+
+// retryAfterSeconds is sent with 429 responses so that the API server backs
+// off briefly before retrying the admission call.
+const retryAfterSeconds = 1
+
+var errAPIRateLimited = errors.New("API server request rate limit exceeded")
+
+// allowAPICall reserves a token for an API server call. It never blocks: when
+// no token is available the caller gets errAPIRateLimited and applies the
+// fail-open/fail-closed policy instead of stalling the admission request.
+func allowAPICall(limiter *rate.Limiter) error {
+	if limiter != nil && !limiter.Allow() {
+		return errAPIRateLimited
+	}
+	return nil
+}
+
+// acquireConcurrency takes a slot for an admission request without waiting,
+// reporting false when all slots are taken.
+func (s *Server) acquireConcurrency() bool {
+	if s.concurrency == nil {
+		return true
+	}
+	select {
+	case s.concurrency <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Server) releaseConcurrency() {
+	if s.concurrency != nil {
+		<-s.concurrency
+	}
+}
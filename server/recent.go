@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// recentDecision is one entry served by /debug/recent.
+type recentDecision struct {
+	Timestamp    time.Time `json:"timestamp"`
+	UID          types.UID `json:"uid"`
+	Namespace    string    `json:"namespace"`
+	Pod          string    `json:"pod,omitempty"`
+	GenerateName string    `json:"generateName,omitempty"`
+	User         string    `json:"user"`
+	Policy       string    `json:"policy"`
+	Decision     string    `json:"decision"`
+	Reason       string    `json:"reason"`
+	Message      string    `json:"message,omitempty"`
+	DryRun       bool      `json:"dryRun"`
+}
+
+// recentDecisions is a fixed-size ring buffer of the latest denials and
+// warnings.
+type recentDecisions struct {
+	mu      sync.Mutex
+	entries []recentDecision
+	next    int
+	full    bool
+}
+
+func newRecentDecisions(size int) *recentDecisions {
+	return &recentDecisions{entries: make([]recentDecision, size)}
+}
+
+func (r *recentDecisions) Add(decision recentDecision) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) == 0 {
+		return
+	}
+	r.entries[r.next] = decision
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// List returns the recorded decisions, newest first.
+func (r *recentDecisions) List() []recentDecision {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := r.next
+	if r.full {
+		count = len(r.entries)
+	}
+	result := make([]recentDecision, 0, count)
+	for i := 1; i <= count; i++ {
+		result = append(result, r.entries[(r.next-i+len(r.entries))%len(r.entries)])
+	}
+	return result
+}
+
+// debugRecent serves the latest denials and warnings as JSON.
+func (s *Server) debugRecent(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.recent.List())
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecentDecisions(t *testing.T) {
+	recent := newRecentDecisions(3)
+	if got := recent.List(); len(got) != 0 {
+		t.Fatalf("expected no decisions, got %v", got)
+	}
+
+	for _, pod := range []string{"a", "b", "c", "d"} {
+		recent.Add(recentDecision{Pod: pod})
+	}
+	var pods []string
+	for _, decision := range recent.List() {
+		pods = append(pods, decision.Pod)
+	}
+	if want := []string{"d", "c", "b"}; !reflect.DeepEqual(pods, want) {
+		t.Errorf("got %v, want %v", pods, want)
+	}
+}
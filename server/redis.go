@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisUserBudgetPrefix is prepended to the user names of the Redis keys
+// holding their grants.
+const redisUserBudgetPrefix = "gpu-webhook:user-budget:"
+
+// reserveScript atomically drops the grants of KEYS[1] that left the window,
+// sums the rest and adds the new grant if it fits. Grants are members of a
+// sorted set, scored by their time in milliseconds and named id:gpus.
+var reserveScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', tonumber(ARGV[1]) - tonumber(ARGV[2]))
+local used = 0
+for _, member in ipairs(redis.call('ZRANGE', KEYS[1], 0, -1)) do
+  used = used + tonumber(string.match(member, ':(%d+)$'))
+end
+if used + tonumber(ARGV[3]) > tonumber(ARGV[4]) then
+  return {used, 0}
+end
+if ARGV[6] == '0' then
+  redis.call('ZADD', KEYS[1], ARGV[1], ARGV[5] .. ':' .. ARGV[3])
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return {used, 1}
+`)
+
+// redisOptions builds the client options for the Redis server in config.
+// Calls honor the deadline of their context, so that Redis shares the
+// request's --api-timeout.
+func redisOptions(config Config) (*redis.Options, error) {
+	options := &redis.Options{
+		Addr:                  config.RedisAddr,
+		Password:              config.RedisPassword,
+		DB:                    config.RedisDB,
+		ContextTimeoutEnabled: true,
+		DisableIdentity:       true,
+	}
+	if config.RedisTLS || config.RedisCAFile != "" {
+		options.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		if config.RedisCAFile != "" {
+			data, err := os.ReadFile(config.RedisCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("read Redis CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no certificates found in Redis CA file %s", config.RedisCAFile)
+			}
+			options.TLSConfig.RootCAs = pool
+		}
+	}
+	return options, nil
+}
+
+// redisUserBudget keeps the grants of userBudget in Redis, so that every
+// replica of the webhook enforces the same budget.
+type redisUserBudget struct {
+	client *redis.Client
+	max    int64
+	window time.Duration
+	now    func() time.Time
+}
+
+func newRedisUserBudget(options *redis.Options, max int64, window time.Duration) *redisUserBudget {
+	return &redisUserBudget{client: redis.NewClient(options), max: max, window: window, now: time.Now}
+}
+
+func (b *redisUserBudget) Reserve(ctx context.Context, user string, gpus int64, dryRun bool) (int64, bool, error) {
+	dryRunArg := "0"
+	if dryRun {
+		dryRunArg = "1"
+	}
+	result, err := reserveScript.Run(ctx, b.client, []string{redisUserBudgetPrefix + user},
+		b.now().UnixMilli(),
+		b.window.Milliseconds(),
+		gpus,
+		b.max,
+		newRequestID(),
+		dryRunArg,
+	).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	if len(result) != 2 {
+		return 0, false, fmt.Errorf("redis: unexpected reply %v to the budget script", result)
+	}
+	return result[0], result[1] == 1, nil
+}
+
+func (b *redisUserBudget) Usage(ctx context.Context) ([]userUsage, error) {
+	// SCAN may return a key more than once.
+	keys := make(map[string]bool)
+	iter := b.client.Scan(ctx, 0, redisUserBudgetPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys[iter.Val()] = true
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	since := strconv.FormatInt(b.now().UnixMilli()-b.window.Milliseconds(), 10)
+	usage := make([]userUsage, 0, len(keys))
+	for key := range keys {
+		members, err := b.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: "(" + since, Max: "+inf"}).Result()
+		if err != nil {
+			return nil, err
+		}
+		var used int64
+		for _, member := range members {
+			gpus, err := strconv.ParseInt(member[strings.LastIndexByte(member, ':')+1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("redis: malformed grant %q in %s", member, key)
+			}
+			used += gpus
+		}
+		if used > 0 {
+			usage = append(usage, userUsage{User: strings.TrimPrefix(key, redisUserBudgetPrefix), GPUs: used})
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].User < usage[j].User })
+	return usage, nil
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	"github.com/redis/go-redis/v9"
+	v1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// newMiniredisBudget returns a budget of max GPUs per hour kept in a
+// miniredis server, with its clock set to now.
+func newMiniredisBudget(t *testing.T, max int64, now *time.Time) (*redisUserBudget, *miniredis.Miniredis) {
+	t.Helper()
+	redisServer := miniredis.RunT(t)
+	budget := newRedisUserBudget(&redis.Options{Addr: redisServer.Addr()}, max, time.Hour)
+	budget.now = func() time.Time { return *now }
+	t.Cleanup(func() { budget.client.Close() })
+	return budget, redisServer
+}
+
+func TestRedisUserBudgetReserve(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	budget, redisServer := newMiniredisBudget(t, 4, &now)
+	ctx := context.Background()
+
+	steps := []struct {
+		advance  time.Duration
+		user     string
+		gpus     int64
+		dryRun   bool
+		wantUsed int64
+		wantOK   bool
+	}{
+		{user: "alice", gpus: 3, wantUsed: 0, wantOK: true},
+		{user: "alice", gpus: 2, wantUsed: 3, wantOK: false},
+		{user: "alice", gpus: 1, dryRun: true, wantUsed: 3, wantOK: true},
+		{user: "alice", gpus: 1, wantUsed: 3, wantOK: true},
+		{user: "bob", gpus: 4, wantUsed: 0, wantOK: true},
+		{advance: 61 * time.Minute, user: "alice", gpus: 4, wantUsed: 0, wantOK: true},
+	}
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		used, ok, err := budget.Reserve(ctx, step.user, step.gpus, step.dryRun)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if used != step.wantUsed || ok != step.wantOK {
+			t.Errorf("step %d: Reserve(%s, %d) = %d, %v, want %d, %v", i, step.user, step.gpus, used, ok, step.wantUsed, step.wantOK)
+		}
+	}
+
+	usage, err := budget.Usage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []userUsage{{User: "alice", GPUs: 4}}
+	if !reflect.DeepEqual(usage, want) {
+		t.Errorf("Usage() = %+v, want %+v", usage, want)
+	}
+	if ttl := redisServer.TTL(redisUserBudgetPrefix + "alice"); ttl != time.Hour {
+		t.Errorf("TTL = %s, want the window", ttl)
+	}
+}
+
+func TestRedisUserBudgetPassword(t *testing.T) {
+	now := time.Now()
+	budget, redisServer := newMiniredisBudget(t, 4, &now)
+	redisServer.RequireAuth("secret")
+	if _, _, err := budget.Reserve(context.Background(), "alice", 1, false); err == nil {
+		t.Fatal("Reserve() succeeded without a password")
+	}
+
+	options, err := redisOptions(Config{RedisAddr: redisServer.Addr(), RedisPassword: "secret", RedisDB: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	budget = newRedisUserBudget(options, 4, time.Hour)
+	defer budget.client.Close()
+	if _, ok, err := budget.Reserve(context.Background(), "alice", 1, false); err != nil || !ok {
+		t.Fatalf("Reserve() = %v, %v, want a grant", ok, err)
+	}
+	redisServer.Select(2)
+	if !redisServer.Exists(redisUserBudgetPrefix + "alice") {
+		t.Error("grant was not recorded in database 2")
+	}
+}
+
+func TestRedisOptionsTLS(t *testing.T) {
+	options, err := redisOptions(Config{RedisAddr: "redis:6379"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.TLSConfig != nil {
+		t.Error("TLS enabled without --redis-tls")
+	}
+
+	options, err = redisOptions(Config{RedisAddr: "redis:6379", RedisTLS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.TLSConfig == nil || options.TLSConfig.RootCAs != nil {
+		t.Errorf("TLSConfig = %+v, want TLS verified against the system roots", options.TLSConfig)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := redisOptions(Config{RedisAddr: "redis:6379", RedisCAFile: caFile}); err == nil || !strings.Contains(err.Error(), "no certificates found") {
+		t.Errorf("error = %v, want an invalid CA file error", err)
+	}
+}
+
+func TestValidatePodUserBudgetUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	raw, err := json.Marshal(corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 1))}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := json.Marshal(v1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &v1.AdmissionRequest{
+			UID:       "abc",
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+			Namespace: "team",
+			Operation: v1.Create,
+			UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, failOpen := range []bool{true, false} {
+		server := newTestServer(policy.Policy{GPUPrefixes: []string{"nvidia.com"}})
+		server.audit = &auditLogger{w: io.Discard}
+		server.evaluator = stubEvaluator(policy.Decision{Allowed: true, Reason: policy.ReasonWithinLimit})
+		server.config.FailOpen = failOpen
+		server.userBudget = newRedisUserBudget(&redis.Options{Addr: addr, MaxRetries: -1}, 4, time.Hour)
+
+		recorder := httptest.NewRecorder()
+		server.validatePod(recorder, admissionRequest("/validate", strings.NewReader(string(body))))
+		var review v1.AdmissionReview
+		if err := json.Unmarshal(recorder.Body.Bytes(), &review); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if review.Response.Allowed != failOpen {
+			t.Errorf("failOpen %v: Allowed = %v, want %v", failOpen, review.Response.Allowed, failOpen)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	v1 "k8s.io/api/admission/v1"
+	"k8s.io/klog/v2"
+)
+
+// regoQuery is evaluated with the AdmissionReview as input. The package may
+// define allow, a boolean, and deny, a set of messages; the request is denied
+// when deny is not empty or allow is false.
+const regoQuery = "data.gpupolicy"
+
+// regoPolicy evaluates a compiled Rego policy.
+type regoPolicy interface {
+	Eval(ctx context.Context, input any) (any, error)
+}
+
+// newRegoPolicy loads the --rego-policy file. It is nil unless the webhook is
+// built with the rego tag, which keeps OPA out of the default binary.
+var newRegoPolicy func(ctx context.Context, path string) (regoPolicy, error)
+
+// evaluateRego decides the admission request with the Rego policy instead of
+// the native policy. A policy that fails to evaluate is handled like a failed
+// lookup, according to --fail-open.
+func (s *Server) evaluateRego(ctx context.Context, ar *v1.AdmissionReview) policy.Decision {
+	decision, err := s.regoDecision(ctx, ar)
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Failed to evaluate rego policy")
+		if s.config.FailOpen {
+			return policy.Decision{Allowed: true, Reason: policy.ReasonRegoFailed}
+		}
+		return policy.Decision{Reason: policy.ReasonRegoFailed, Message: fmt.Sprintf("unable to evaluate GPU policy: %v", err)}
+	}
+	return decision
+}
+
+func (s *Server) regoDecision(ctx context.Context, ar *v1.AdmissionReview) (policy.Decision, error) {
+	// Round-trip through JSON so the policy sees the field names it would
+	// see on the wire.
+	data, err := json.Marshal(ar)
+	if err != nil {
+		return policy.Decision{}, err
+	}
+	var input map[string]any
+	if err := json.Unmarshal(data, &input); err != nil {
+		return policy.Decision{}, err
+	}
+	result, err := s.rego.Eval(ctx, input)
+	if err != nil {
+		return policy.Decision{}, err
+	}
+	document, ok := result.(map[string]any)
+	if !ok {
+		return policy.Decision{}, fmt.Errorf("%s is undefined or not an object", regoQuery)
+	}
+
+	var messages []string
+	if deny, ok := document["deny"]; ok {
+		items, ok := deny.([]any)
+		if !ok {
+			return policy.Decision{}, fmt.Errorf("%s.deny must be a set of strings", regoQuery)
+		}
+		for _, item := range items {
+			message, ok := item.(string)
+			if !ok {
+				return policy.Decision{}, fmt.Errorf("%s.deny must be a set of strings", regoQuery)
+			}
+			messages = append(messages, message)
+		}
+	}
+	if len(messages) > 0 {
+		return policy.Decision{Reason: policy.ReasonRegoDenied, Message: strings.Join(messages, "; ")}, nil
+	}
+	if allow, ok := document["allow"]; ok {
+		allowed, ok := allow.(bool)
+		if !ok {
+			return policy.Decision{}, fmt.Errorf("%s.allow must be a boolean", regoQuery)
+		}
+		if !allowed {
+			return policy.Decision{Reason: policy.ReasonRegoDenied, Message: "denied by the GPU rego policy"}, nil
+		}
+	}
+	return policy.Decision{Allowed: true, Reason: policy.ReasonRegoAllowed}, nil
+}
@@ -0,0 +1,38 @@
+//go:build rego
+
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+func init() {
+	newRegoPolicy = loadRegoPolicy
+}
+
+// opaPolicy is a regoPolicy prepared once and evaluated on every request.
+type opaPolicy struct {
+	query rego.PreparedEvalQuery
+}
+
+func loadRegoPolicy(ctx context.Context, path string) (regoPolicy, error) {
+	query, err := rego.New(rego.Query(regoQuery), rego.Load([]string{path}, nil)).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("prepare rego policy %s: %w", path, err)
+	}
+	return &opaPolicy{query: query}, nil
+}
+
+func (p *opaPolicy) Eval(ctx context.Context, input any) (any, error) {
+	results, err := p.query.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 || len(results[0].Expressions) == 0 {
+		return nil, nil
+	}
+	return results[0].Expressions[0].Value, nil
+}
@@ -0,0 +1,76 @@
+//go:build rego
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	v1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// samplePolicy is the example from the README.
+const samplePolicy = `package gpupolicy
+
+import rego.v1
+
+deny contains msg if {
+	some c in input.request.object.spec.containers
+	to_number(c.resources.limits["nvidia.com/gpu"]) > 4
+	msg := sprintf("container %s requests more than 4 GPUs", [c.name])
+}
+`
+
+func TestRegoPolicyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gpupolicy.rego")
+	if err := os.WriteFile(path, []byte(samplePolicy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rego, err := loadRegoPolicy(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(policy.Policy{})
+	server.rego = rego
+
+	tests := []struct {
+		name        string
+		gpus        int64
+		wantAllowed bool
+		wantReason  string
+		wantMessage string
+	}{
+		{name: "within limit", gpus: 4, wantAllowed: true, wantReason: policy.ReasonRegoAllowed},
+		{name: "over limit", gpus: 8, wantReason: policy.ReasonRegoDenied, wantMessage: "container app requests more than 4 GPUs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", tt.gpus))}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ar := &v1.AdmissionReview{Request: &v1.AdmissionRequest{UID: "abc", Namespace: "default", Object: runtime.RawExtension{Raw: raw}}}
+			decision := server.evaluateRego(context.Background(), ar)
+			if decision.Allowed != tt.wantAllowed || decision.Reason != tt.wantReason || decision.Message != tt.wantMessage {
+				t.Errorf("got allowed=%v reason=%s message=%q, want allowed=%v reason=%s message=%q",
+					decision.Allowed, decision.Reason, decision.Message, tt.wantAllowed, tt.wantReason, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestRegoPolicyFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gpupolicy.rego")
+	if err := os.WriteFile(path, []byte("package gpupolicy\n\ndeny contains"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRegoPolicy(context.Background(), path); err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
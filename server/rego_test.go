@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	v1 "k8s.io/api/admission/v1"
+)
+
+// stubRego returns a fixed result, or err, for every input.
+type stubRego struct {
+	result any
+	err    error
+}
+
+func (r stubRego) Eval(ctx context.Context, input any) (any, error) {
+	if _, ok := input.(map[string]any)["request"]; !ok {
+		return nil, errors.New("input has no request")
+	}
+	return r.result, r.err
+}
+
+func TestEvaluateRego(t *testing.T) {
+	tests := []struct {
+		name        string
+		rego        stubRego
+		failOpen    bool
+		wantAllowed bool
+		wantReason  string
+		wantMessage string
+	}{
+		{name: "allowed", rego: stubRego{result: map[string]any{"allow": true}}, wantAllowed: true, wantReason: policy.ReasonRegoAllowed},
+		{name: "empty document", rego: stubRego{result: map[string]any{}}, wantAllowed: true, wantReason: policy.ReasonRegoAllowed},
+		{name: "allow false", rego: stubRego{result: map[string]any{"allow": false}}, wantReason: policy.ReasonRegoDenied, wantMessage: "denied by the GPU rego policy"},
+		{
+			name:        "deny messages",
+			rego:        stubRego{result: map[string]any{"allow": true, "deny": []any{"too many GPUs", "wrong product"}}},
+			wantReason:  policy.ReasonRegoDenied,
+			wantMessage: "too many GPUs; wrong product",
+		},
+		{name: "undefined", rego: stubRego{}, wantReason: policy.ReasonRegoFailed, wantMessage: "unable to evaluate GPU policy: data.gpupolicy is undefined or not an object"},
+		{name: "invalid deny", rego: stubRego{result: map[string]any{"deny": "no"}}, wantReason: policy.ReasonRegoFailed, wantMessage: "unable to evaluate GPU policy: data.gpupolicy.deny must be a set of strings"},
+		{name: "error fails closed", rego: stubRego{err: errors.New("boom")}, wantReason: policy.ReasonRegoFailed, wantMessage: "unable to evaluate GPU policy: boom"},
+		{name: "error fails open", rego: stubRego{err: errors.New("boom")}, failOpen: true, wantAllowed: true, wantReason: policy.ReasonRegoFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(policy.Policy{})
+			server.rego = tt.rego
+			server.config.FailOpen = tt.failOpen
+
+			decision := server.evaluateRego(context.Background(), &v1.AdmissionReview{Request: &v1.AdmissionRequest{UID: "abc", Namespace: "default"}})
+			if decision.Allowed != tt.wantAllowed || decision.Reason != tt.wantReason || decision.Message != tt.wantMessage {
+				t.Errorf("got allowed=%v reason=%s message=%q, want allowed=%v reason=%s message=%q",
+					decision.Allowed, decision.Reason, decision.Message, tt.wantAllowed, tt.wantReason, tt.wantMessage)
+			}
+		})
+	}
+}
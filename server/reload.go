@@ -0,0 +1,66 @@
+package server
+
+import (
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	"k8s.io/klog/v2"
+)
+
+// watchPolicy reloads the policy whenever the file changes. The parent
+// directory is watched rather than the file itself so that the atomic symlink
+// swap used for mounted ConfigMaps is picked up as well.
+func (s *Server) watchPolicy(path string, defaults policy.Policy) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+					continue
+				}
+				p, err := policy.Load(path, defaults)
+				if err != nil {
+					klog.Errorf("Failed to reload policy from %s, keeping previous policy: %v", path, err)
+					continue
+				}
+				s.setPolicy(p)
+				klog.Infof("Reloaded policy from %s", path)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Errorf("Policy watcher error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *Server) currentPolicy() *policy.Policy {
+	s.policyMu.RLock()
+	defer s.policyMu.RUnlock()
+	return s.policy
+}
+
+func (s *Server) setPolicy(p *policy.Policy) {
+	s.policyMu.Lock()
+	defer s.policyMu.Unlock()
+	s.policy = p
+	if s.policies != nil {
+		s.policies.purge()
+	}
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"k8s.io/klog/v2"
+)
+
+// requestIDHeader carries the ID that ties together the log lines of one
+// admission request. A caller's ID is kept, otherwise the AdmissionReview's
+// UID is used so that the logs can be matched with the API server's.
+const requestIDHeader = "X-Request-Id"
+
+// newRequestID returns a random ID for requests that cannot be matched to an
+// AdmissionReview.
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// withRequestID sets the response header and returns a context whose logger
+// includes the ID.
+func withRequestID(ctx context.Context, w http.ResponseWriter, id string) context.Context {
+	w.Header().Set(requestIDHeader, id)
+	return klog.NewContext(ctx, klog.FromContext(ctx).WithValues("requestID", id))
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// resizeSubresource is the pods subresource used for in-place resizes.
+const resizeSubresource = "resize"
+
+// gpusIncreased reports whether an in-place resize, or an update under
+// Config.UpdateGrace, raises any of the pod's GPU requests above those of
+// old. Changes that keep or lower them are allowed without evaluating the
+// policy again, so a pod admitted under an older, looser policy can still be
+// shrunk or updated.
+func gpusIncreased(p *policy.Policy, old, pod *corev1.Pod) bool {
+	oldRequests := p.PodGPURequestsByResource(old)
+	for resourceName, quantity := range p.PodGPURequestsByResource(pod) {
+		if quantity > oldRequests[resourceName] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	v1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidatePodResize(t *testing.T) {
+	server := newTestServer(policy.Policy{GPUPrefixes: []string{"nvidia.com"}})
+	server.audit = &auditLogger{w: io.Discard}
+	server.evaluator = stubEvaluator(policy.Decision{Reason: policy.ReasonMaxGPUsExceeded, Message: "too many GPUs"})
+
+	gpuPod := func(count int64) []byte {
+		raw, err := json.Marshal(corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", count))}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	tests := []struct {
+		name        string
+		subResource string
+		old, new    int64
+		updateGrace bool
+		wantAllowed bool
+	}{
+		{name: "resize lowering GPUs", subResource: "resize", old: 4, new: 2, wantAllowed: true},
+		{name: "resize keeping GPUs", subResource: "resize", old: 4, new: 4, wantAllowed: true},
+		{name: "resize adding GPUs", subResource: "resize", old: 2, new: 4, wantAllowed: false},
+		{name: "update", old: 4, new: 2, wantAllowed: false},
+		{name: "update with grace lowering GPUs", old: 4, new: 2, updateGrace: true, wantAllowed: true},
+		{name: "update with grace keeping GPUs", old: 4, new: 4, updateGrace: true, wantAllowed: true},
+		{name: "update with grace adding GPUs", old: 2, new: 4, updateGrace: true, wantAllowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.config.UpdateGrace = tt.updateGrace
+			body, err := json.Marshal(v1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &v1.AdmissionRequest{
+					UID:         "abc",
+					Namespace:   "default",
+					Operation:   v1.Update,
+					SubResource: tt.subResource,
+					Object:      runtime.RawExtension{Raw: gpuPod(tt.new)},
+					OldObject:   runtime.RawExtension{Raw: gpuPod(tt.old)},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			server.validatePod(recorder, admissionRequest("/validate", strings.NewReader(string(body))))
+			var review v1.AdmissionReview
+			if err := json.Unmarshal(recorder.Body.Bytes(), &review); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if review.Response.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v", review.Response.Allowed, tt.wantAllowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+// apiBackoff bounds the retries of a failed API server call. The admission
+// timeout still applies: a call whose context is done is not retried.
+var apiBackoff = wait.Backoff{
+	Steps:    4,
+	Duration: 50 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+}
+
+// isTransientAPIError reports whether err is worth retrying: a 5xx or 429
+// from the API server, or a dropped connection.
+func isTransientAPIError(err error) bool {
+	return apierrors.IsInternalError(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsUnexpectedServerError(err) ||
+		utilnet.IsConnectionRefused(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsProbableEOF(err)
+}
+
+// callAPI runs fn, an API server call, retrying transient errors with
+// apiBackoff. Every attempt takes a token from limiter, and running out of
+// tokens is not retried. The last error is returned once the retries are
+// exhausted, so that the fail-open/fail-closed policy only applies then.
+func callAPI(ctx context.Context, limiter *rate.Limiter, call string, fn func() error) error {
+	var attempt int
+	var lastErr error
+	return retry.OnError(apiBackoff, func(err error) bool {
+		return ctx.Err() == nil && isTransientAPIError(err)
+	}, func() error {
+		attempt++
+		if attempt > 1 {
+			klog.FromContext(ctx).V(4).Info("Retrying API call", "call", call, "attempt", attempt, "err", lastErr)
+		}
+		if err := allowAPICall(limiter); err != nil {
+			return err
+		}
+		lastErr = fn()
+		return lastErr
+	})
+}
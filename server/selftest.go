@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+// selfTestNamespace is the namespace of the synthetic pod evaluated at
+// startup. It has no labels or annotations.
+const selfTestNamespace = "gpu-policy-self-test"
+
+// selfTestResource derives a GPU resource name from the first GPU prefix, or
+// suffix, of p. Glob and regex patterns cannot be turned into a name.
+func selfTestResource(p *policy.Policy) (corev1.ResourceName, bool) {
+	if len(p.GPUPrefixes) > 0 && (p.GPUMatchMode == "" || p.GPUMatchMode == policy.MatchModePrefix) {
+		prefix := p.GPUPrefixes[0]
+		if !strings.Contains(prefix, "/") {
+			prefix += "/gpu"
+		}
+		return corev1.ResourceName(prefix), true
+	}
+	if len(p.GPUSuffixes) > 0 {
+		return corev1.ResourceName("example.com/self-test" + p.GPUSuffixes[0]), true
+	}
+	return "", false
+}
+
+// selfTest runs a synthetic pod requesting one GPU through the evaluator
+// with p, without calling the API server, and logs the verdict. It returns an
+// error when the policy does not recognize the GPU or the evaluator panics.
+func (s *Server) selfTest(p *policy.Policy) (err error) {
+	resourceName, ok := selfTestResource(p)
+	if !ok {
+		klog.Infof("Skipping self-test of policy %s: no GPU resource name can be derived from its patterns", p.DisplayName())
+		return nil
+	}
+	quantity := resource.MustParse("1")
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "self-test", Namespace: selfTestNamespace},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:  "self-test",
+			Image: "self-test",
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{resourceName: quantity},
+				Limits:   corev1.ResourceList{resourceName: quantity},
+			},
+		}}},
+	}
+	if _, found := p.FindGPUResource(pod); !found {
+		return fmt.Errorf("policy %s does not treat %s as a GPU", p.DisplayName(), resourceName)
+	}
+
+	evaluator := &policy.Evaluator{}
+	if configured, ok := s.evaluator.(*policy.Evaluator); ok {
+		*evaluator = *configured
+	}
+	evaluator.Cluster = policy.StaticCluster{Namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: selfTestNamespace}}}
+	evaluator.Policies = nil
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("evaluating policy %s panicked: %v", p.DisplayName(), r)
+		}
+	}()
+	decision := evaluator.Evaluate(context.Background(), p, pod, selfTestNamespace)
+	klog.InfoS("Policy self-test",
+		"policy", p.DisplayName(),
+		"resource", resourceName,
+		"allowed", decision.Allowed,
+		"reason", decision.Reason,
+		"message", decision.Message,
+	)
+	return nil
+}
+
+// runSelfTests self-tests the default policy and its named policies. The
+// first failure is kept for readyz, so a broken policy never takes traffic.
+func (s *Server) runSelfTests() {
+	p := s.currentPolicy()
+	policies := []*policy.Policy{p}
+	for _, named := range p.Routes() {
+		policies = append(policies, named)
+	}
+	for _, p := range policies {
+		if err := s.selfTest(p); err != nil {
+			klog.Errorf("Policy self-test failed: %v", err)
+			if s.selfTestErr == nil {
+				s.selfTestErr = err
+			}
+		}
+	}
+}
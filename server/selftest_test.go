@@ -0,0 +1,53 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+)
+
+func TestSelfTest(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   policy.Policy
+		resource string
+	}{
+		{name: "prefix", policy: policy.Policy{GPUPrefixes: []string{"nvidia.com"}, MaxGPUsPerPod: 1}, resource: "nvidia.com/gpu"},
+		{name: "resource prefix", policy: policy.Policy{GPUPrefixes: []string{"amd.com/gpu"}}, resource: "amd.com/gpu"},
+		{name: "suffix", policy: policy.Policy{GPUSuffixes: []string{"-gpu"}}, resource: "example.com/self-test-gpu"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resourceName, ok := selfTestResource(&tt.policy)
+			if !ok || string(resourceName) != tt.resource {
+				t.Errorf("resource = %q, %v, want %q", resourceName, ok, tt.resource)
+			}
+			server := newTestServer(tt.policy)
+			if err := server.selfTest(server.currentPolicy()); err != nil {
+				t.Errorf("self-test failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestReadyzSelfTestFailure(t *testing.T) {
+	server := newTestServer(policy.Policy{GPUPrefixes: []string{"nvidia.com"}})
+	server.listening.Store(true)
+	server.clientset = nil
+
+	recorder := httptest.NewRecorder()
+	server.readyz(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+
+	server.selfTestErr = errors.New("policy default does not treat nvidia.com/gpu as a GPU")
+	recorder = httptest.NewRecorder()
+	server.readyz(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusServiceUnavailable)
+	}
+}
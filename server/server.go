@@ -0,0 +1,891 @@
+// Package server serves the GPU policy as a validating and mutating admission
+// webhook.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/time/rate"
+	"k8s.io/api/admission/v1"
+	"k8s.io/api/admission/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog/v2"
+)
+
+// DefaultMaxRequestBytes matches the API server's own limit on request bodies.
+const DefaultMaxRequestBytes = 3 * 1024 * 1024
+
+// DefaultAPITimeout leaves headroom below the 10s default webhook timeout.
+const DefaultAPITimeout = 5 * time.Second
+
+// Default connection timeouts. The write timeout covers the 30s maximum
+// webhook timeout, after which the API server has given up on the request.
+const (
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultReadTimeout       = 15 * time.Second
+	DefaultWriteTimeout      = 30 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+)
+
+// Verdicts for requests that cannot be decoded.
+const (
+	OnErrorAllow = "allow"
+	OnErrorDeny  = "deny"
+)
+
+// Config holds the settings of the webhook server that are not part of the
+// policy.
+type Config struct {
+	// BindAddress is the IP address the webhook listens on.
+	BindAddress string
+	Port        int
+	MetricsPort int
+	// PathPrefix, e.g. /gpu-webhook, is prepended to the admission paths so
+	// they do not collide behind a shared ingress. The metrics port is
+	// scraped and probed directly and ignores it.
+	PathPrefix string
+	CertFile   string
+	KeyFile    string
+	// ClientCAFile, when set, requires clients to present a certificate
+	// signed by one of its CAs. ClientCertNames optionally restricts the
+	// accepted common names and DNS names.
+	ClientCAFile    string
+	ClientCertNames []string
+
+	Kubeconfig string
+	APIQPS     float32
+	APIBurst   int
+	// APITimeout bounds the API server calls made for a single request.
+	APITimeout time.Duration
+
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout and IdleTimeout bound
+	// the webhook's client connections like their http.Server counterparts.
+	// Zero means no timeout.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	ShutdownGracePeriod time.Duration
+	AuditLogPath        string
+	EmitEvents          bool
+	EventThrottle       time.Duration
+	EnableDebug         bool
+	RecentDecisions     int
+
+	// NamespaceAllowLabel is the key=value label that opts a namespace in to
+	// GPU usage.
+	NamespaceAllowLabel string
+	NamespaceCacheTTL   time.Duration
+	// NamespaceCacheSize bounds the namespaces cached for NamespaceCacheTTL
+	// or, with UseInformers, the namespaces whose resolved policy is cached.
+	NamespaceCacheSize int
+	UseInformers       bool
+
+	FailOpen           bool
+	OnError            string
+	CheckResourceQuota bool
+	// ApplyLimitRangeDefaults evaluates pods with their namespace's
+	// LimitRange defaults applied, at the cost of an API call per pod.
+	ApplyLimitRangeDefaults bool
+	// CheckNodeCapacity denies pods requesting more GPUs than the largest
+	// node can allocate.
+	CheckNodeCapacity bool
+	MaxRequestBytes   int64
+	// MaxConcurrentRequests limits the admission requests handled at once,
+	// answering the rest with 429. Zero means no limit.
+	MaxConcurrentRequests int
+
+	// AnnotateDecisions makes /mutate record DecisionAnnotation on allowed
+	// GPU pods.
+	AnnotateDecisions bool
+
+	// PolicyFile is reloaded whenever it changes. Empty serves the defaults.
+	PolicyFile string
+	// PolicyConfigMap, in namespace/name form, is watched through the API
+	// server as an alternative to PolicyFile.
+	PolicyConfigMap string
+	// RequireClientset fails New when no clientset can be built. Otherwise
+	// the webhook runs without one unless an enabled feature needs it.
+	RequireClientset bool
+
+	// TemplatePaths maps custom resources, as group/version/resource, to the
+	// JSONPath of the pod template they embed. They are validated on
+	// /validate-templates.
+	TemplatePaths map[string]string
+
+	// RegoPolicy, when set, is a Rego file deciding admission requests in
+	// place of the native policy. It needs a binary built with the rego tag.
+	RegoPolicy string
+
+	// DelegateURL, when set, receives the AdmissionReview of every GPU pod
+	// the policy allows and can still deny it within DelegateTimeout.
+	DelegateURL     string
+	DelegateTimeout time.Duration
+
+	// MaxGPUsPerUser caps the GPUs granted to pods created by a single user
+	// within UserBudgetWindow. Zero disables the budget.
+	MaxGPUsPerUser   int64
+	UserBudgetWindow time.Duration
+	// RedisAddr, in host:port form, shares the user budgets between
+	// replicas through Redis instead of keeping them in memory.
+	RedisAddr     string
+	RedisPassword string
+	RedisDB       int
+	// RedisTLS connects to Redis over TLS, verifying it against the system
+	// roots or, when set, the CAs in RedisCAFile.
+	RedisTLS    bool
+	RedisCAFile string
+
+	// UpdateGrace allows updates that do not raise a pod's GPU requests
+	// without evaluating the policy, as is always done for resizes, so that
+	// tightening the policy does not block changes to existing pods.
+	UpdateGrace bool
+
+	// BuildInfo is reported on /version.
+	BuildInfo BuildInfo
+}
+
+// PodEvaluator evaluates a pod against a policy. It is implemented by
+// *policy.Evaluator and lets the handlers be tested without a cluster.
+type PodEvaluator interface {
+	Evaluate(ctx context.Context, p *policy.Policy, pod *corev1.Pod, namespace string) policy.Decision
+}
+
+// Server serves the admission webhook and its metrics and debug endpoints.
+type Server struct {
+	config  Config
+	scheme  *runtime.Scheme
+	decoder *serializer.CodecFactory
+
+	clientset  kubernetes.Interface
+	apiLimiter *rate.Limiter
+
+	policyMu  sync.RWMutex
+	policy    *policy.Policy
+	evaluator PodEvaluator
+	rego      regoPolicy
+	delegate  *delegate
+	// userBudget is nil unless Config.MaxGPUsPerUser is set.
+	userBudget userBudgetStore
+
+	templatePaths map[schema.GroupVersionResource]*templatePath
+
+	namespaces namespaceGetter
+	// policies caches the resolved namespace policies, nil without
+	// informers.
+	policies *policyCache
+
+	audit  *auditLogger
+	events *eventEmitter
+	recent *recentDecisions
+
+	onError         string
+	maxRequestBytes int64
+	// concurrency holds a token per admission request being handled, nil
+	// when concurrency is not limited.
+	concurrency chan struct{}
+	apiTimeout  time.Duration
+
+	podLister       corelisters.PodLister
+	nodeLister      corelisters.NodeLister
+	informersSynced func() bool
+	// selfTestErr is the first policy self-test failure at startup.
+	selfTestErr error
+
+	listening atomic.Bool
+	inFlight  atomic.Int64
+}
+
+func newServer() *Server {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = v1.AddToScheme(scheme)
+	_ = v1beta1.AddToScheme(scheme)
+	_ = appsv1.AddToScheme(scheme)
+	_ = batchv1.AddToScheme(scheme)
+	codecFactory := serializer.NewCodecFactory(scheme)
+	s := &Server{
+		scheme:          scheme,
+		decoder:         &codecFactory,
+		maxRequestBytes: DefaultMaxRequestBytes,
+		apiTimeout:      DefaultAPITimeout,
+	}
+	s.evaluator = &policy.Evaluator{Cluster: s}
+	return s
+}
+
+// New creates a server enforcing defaults, or the policy file on top of them
+// when one is configured. It connects to the API server but does not start
+// serving until Run is called.
+func New(ctx context.Context, config Config, defaults policy.Policy) (*Server, error) {
+	if config.PathPrefix != "" && !strings.HasPrefix(config.PathPrefix, "/") {
+		return nil, fmt.Errorf("invalid path prefix %q, must start with /", config.PathPrefix)
+	}
+	config.PathPrefix = strings.TrimSuffix(config.PathPrefix, "/")
+	s := newServer()
+	s.config = config
+	s.setPolicy(&defaults)
+	if config.PolicyFile != "" {
+		p, err := policy.Load(config.PolicyFile, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("load policy: %w", err)
+		}
+		s.setPolicy(p)
+		for path, named := range p.Routes() {
+			klog.Infof("Serving policy %s on %s", named.DisplayName(), path)
+		}
+		if err := s.watchPolicy(config.PolicyFile, defaults); err != nil {
+			return nil, fmt.Errorf("watch policy file: %w", err)
+		}
+	}
+	var configMapNamespace, configMapName string
+	if config.PolicyConfigMap != "" {
+		if config.PolicyFile != "" {
+			return nil, fmt.Errorf("a policy file and a policy ConfigMap cannot both be set")
+		}
+		var err error
+		configMapNamespace, configMapName, err = parseConfigMapRef(config.PolicyConfigMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key, value, ok := strings.Cut(config.NamespaceAllowLabel, "=")
+	if !ok || key == "" {
+		return nil, fmt.Errorf("invalid namespace allow label %q, expected key=value", config.NamespaceAllowLabel)
+	}
+	if config.OnError != OnErrorAllow && config.OnError != OnErrorDeny {
+		return nil, fmt.Errorf("invalid on-error verdict %q, must be %s or %s", config.OnError, OnErrorAllow, OnErrorDeny)
+	}
+	if config.BindAddress != "localhost" && net.ParseIP(config.BindAddress) == nil {
+		return nil, fmt.Errorf("invalid bind address %q, must be an IP address such as 0.0.0.0 or 127.0.0.1", config.BindAddress)
+	}
+	if len(config.ClientCertNames) > 0 && config.ClientCAFile == "" {
+		return nil, fmt.Errorf("client certificate names require a client CA file")
+	}
+	if config.APITimeout <= 0 {
+		return nil, fmt.Errorf("invalid API timeout %s, must be positive", config.APITimeout)
+	}
+	if config.NamespaceCacheSize <= 0 {
+		return nil, fmt.Errorf("invalid namespace cache size %d, must be positive", config.NamespaceCacheSize)
+	}
+	if config.RecentDecisions < 0 {
+		return nil, fmt.Errorf("invalid number of recent decisions %d, must not be negative", config.RecentDecisions)
+	}
+	// Without informers, namespaces expire from the namespaceCache after
+	// NamespaceCacheTTL, and so would their resolved policies.
+	var policies policy.PolicyCache
+	if config.UseInformers {
+		s.policies = newPolicyCache(config.NamespaceCacheSize)
+		policies = s.policies
+	}
+	s.evaluator = &policy.Evaluator{
+		Cluster:                 s,
+		Policies:                policies,
+		AllowLabelKey:           key,
+		AllowLabelValue:         value,
+		FailOpen:                config.FailOpen,
+		CheckResourceQuota:      config.CheckResourceQuota,
+		ApplyLimitRangeDefaults: config.ApplyLimitRangeDefaults,
+		CheckNodeCapacity:       config.CheckNodeCapacity,
+	}
+	s.onError = config.OnError
+	s.maxRequestBytes = config.MaxRequestBytes
+	if config.MaxConcurrentRequests < 0 {
+		return nil, fmt.Errorf("max concurrent requests must not be negative, got %d", config.MaxConcurrentRequests)
+	}
+	if config.MaxConcurrentRequests > 0 {
+		s.concurrency = make(chan struct{}, config.MaxConcurrentRequests)
+	}
+	s.apiTimeout = config.APITimeout
+	templatePaths, err := parseTemplatePaths(config.TemplatePaths)
+	if err != nil {
+		return nil, err
+	}
+	s.templatePaths = templatePaths
+	if config.RegoPolicy != "" {
+		if newRegoPolicy == nil {
+			return nil, fmt.Errorf("a rego policy requires a webhook built with the rego tag")
+		}
+		rego, err := newRegoPolicy(ctx, config.RegoPolicy)
+		if err != nil {
+			return nil, err
+		}
+		s.rego = rego
+		klog.Infof("Deciding admission requests with rego policy %s", config.RegoPolicy)
+	}
+	if config.DelegateURL != "" {
+		delegate, err := newDelegate(config.DelegateURL, config.DelegateTimeout)
+		if err != nil {
+			return nil, err
+		}
+		s.delegate = delegate
+		klog.Infof("Forwarding allowed GPU pods to delegate %s", redactURL(config.DelegateURL))
+	}
+	if config.MaxGPUsPerUser > 0 {
+		if config.UserBudgetWindow <= 0 {
+			return nil, fmt.Errorf("user budget window must be positive, got %s", config.UserBudgetWindow)
+		}
+		if config.RedisAddr != "" {
+			options, err := redisOptions(config)
+			if err != nil {
+				return nil, err
+			}
+			s.userBudget = newRedisUserBudget(options, config.MaxGPUsPerUser, config.UserBudgetWindow)
+			klog.Infof("Sharing user GPU budgets through Redis at %s (database %d, TLS %v)", config.RedisAddr, config.RedisDB, options.TLSConfig != nil)
+		} else {
+			s.userBudget = newUserBudget(config.MaxGPUsPerUser, config.UserBudgetWindow)
+		}
+	}
+
+	if err := s.initClientset(); err != nil {
+		if config.RequireClientset {
+			return nil, err
+		}
+		if features := clientsetFeatures(config, s.currentPolicy()); len(features) > 0 {
+			return nil, fmt.Errorf("%w, which is required by %s", err, strings.Join(features, ", "))
+		}
+		klog.Warningf("Running without a kubernetes clientset, namespace lookups fail and are handled according to --fail-open: %v", err)
+	}
+	if config.PolicyConfigMap != "" {
+		p, err := s.loadPolicyConfigMap(ctx, configMapNamespace, configMapName, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("load policy ConfigMap: %w", err)
+		}
+		s.setPolicy(p)
+		for path, named := range p.Routes() {
+			klog.Infof("Serving policy %s on %s", named.DisplayName(), path)
+		}
+		if err := s.watchPolicyConfigMap(ctx, configMapNamespace, configMapName, defaults); err != nil {
+			return nil, fmt.Errorf("watch policy ConfigMap: %w", err)
+		}
+	}
+	switch {
+	case s.clientset == nil:
+		s.namespaces = noNamespaces{}
+	case config.UseInformers:
+		if err := s.startInformers(ctx, listsPods(config, s.currentPolicy()), config.CheckNodeCapacity); err != nil {
+			return nil, fmt.Errorf("start informers: %w", err)
+		}
+	default:
+		s.namespaces = newNamespaceCache(s.clientset, s.apiLimiter, config.NamespaceCacheTTL, config.NamespaceCacheSize)
+	}
+
+	audit, err := newAuditLogger(config.AuditLogPath)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	s.audit = audit
+	if config.EmitEvents {
+		s.events = newEventEmitter(s.clientset, config.EventThrottle)
+	}
+	if config.EnableDebug {
+		s.recent = newRecentDecisions(config.RecentDecisions)
+	}
+	s.runSelfTests()
+	return s, nil
+}
+
+// Run serves the webhook and metrics endpoints until ctx is cancelled, then
+// drains in-flight requests for up to the shutdown grace period.
+func (s *Server) Run(ctx context.Context) error {
+	mux := http.NewServeMux()
+	prefix := s.config.PathPrefix
+	mux.HandleFunc(prefix+"/validate", s.validatePod)
+	mux.HandleFunc(prefix+policy.NamedPolicyPrefix, s.validateNamedPod)
+	mux.HandleFunc(prefix+"/mutate", s.mutatePod)
+	mux.HandleFunc(prefix+"/validate-workloads", s.validateWorkload)
+	mux.HandleFunc(prefix+"/validate-templates", s.validateTemplate)
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", promhttp.Handler())
+	metricsMux.HandleFunc("/healthz", s.healthz)
+	metricsMux.HandleFunc("/readyz", s.readyz)
+	metricsMux.HandleFunc("/version", s.version)
+	if s.config.EnableDebug {
+		metricsMux.HandleFunc("/debug/evaluate", s.debugEvaluate)
+		metricsMux.HandleFunc("/debug/recent", s.debugRecent)
+		metricsMux.HandleFunc("/debug/policy", s.debugPolicy)
+		metricsMux.HandleFunc("/debug/users", s.debugUsers)
+	}
+	go func() {
+		klog.Infof("Starting metrics server on port %d", s.config.MetricsPort)
+		metricsServer := &http.Server{
+			Addr:              fmt.Sprintf(":%d", s.config.MetricsPort),
+			Handler:           metricsMux,
+			ReadHeaderTimeout: s.config.ReadHeaderTimeout,
+		}
+		if err := metricsServer.ListenAndServe(); err != nil {
+			klog.Fatalf("Failed to start metrics server: %v", err)
+		}
+	}()
+
+	// Set up TLS
+	certs, err := newCertificateReloader(s.config.CertFile, s.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS certificate: %w", err)
+	}
+	if err := certs.watch(); err != nil {
+		return fmt.Errorf("watch TLS certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+	}
+	if s.config.ClientCAFile != "" {
+		if err := clientAuthConfig(tlsConfig, s.config.ClientCAFile, s.config.ClientCertNames); err != nil {
+			return err
+		}
+	}
+	srv := &http.Server{
+		Addr:              net.JoinHostPort(s.config.BindAddress, strconv.Itoa(s.config.Port)),
+		Handler:           mux,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: s.config.ReadHeaderTimeout,
+		ReadTimeout:       s.config.ReadTimeout,
+		WriteTimeout:      s.config.WriteTimeout,
+		IdleTimeout:       s.config.IdleTimeout,
+	}
+
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	s.listening.Store(true)
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		s.listening.Store(false)
+		klog.Infof("Shutting down webhook server with %d requests in flight", s.inFlight.Load())
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownGracePeriod)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			klog.Errorf("Failed to drain in-flight requests: %v", err)
+		}
+	}()
+
+	klog.Infof("Starting webhook server on %s with GPU prefixes: %v, suffixes: %v", srv.Addr, s.currentPolicy().GPUPrefixes, s.currentPolicy().GPUSuffixes)
+	if err := srv.ServeTLS(ln, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	<-shutdownDone
+	return nil
+}
+
+// admitFunc computes the admission response for a decoded pod. old is the
+// pod decoded from the old object of an update, nil otherwise.
+type admitFunc func(ctx context.Context, ar *v1.AdmissionReview, pod, old *corev1.Pod) *v1.AdmissionResponse
+
+// podDecoder extracts the pod to evaluate from the object under admission.
+type podDecoder func(request *v1.AdmissionRequest) (*corev1.Pod, error)
+
+// requestPod decodes the pod under admission.
+func requestPod(request *v1.AdmissionRequest) (*corev1.Pod, error) {
+	return decodePod(request.Object.Raw)
+}
+
+func decodePod(raw []byte) (*corev1.Pod, error) {
+	pod := &corev1.Pod{}
+	if err := json.Unmarshal(raw, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+func (s *Server) validatePod(w http.ResponseWriter, r *http.Request) {
+	s.serveAdmission(w, r, requestPod, s.admitValidate)
+}
+
+// validateNamedPod serves the named policies from the config file, which are
+// looked up on every request so that paths added by a reload take effect
+// without a restart.
+func (s *Server) validateNamedPod(w http.ResponseWriter, r *http.Request) {
+	p := s.currentPolicy().ForPath(strings.TrimPrefix(r.URL.Path, s.config.PathPrefix))
+	if p == nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.serveAdmission(w, r, requestPod, func(ctx context.Context, ar *v1.AdmissionReview, pod, old *corev1.Pod) *v1.AdmissionResponse {
+		return s.admit(ctx, p, ar, pod, old)
+	})
+}
+
+func (s *Server) serveAdmission(w http.ResponseWriter, r *http.Request, decode podDecoder, admit admitFunc) {
+	requestID := r.Header.Get(requestIDHeader)
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	reqCtx := withRequestID(r.Context(), w, requestID)
+	klog.FromContext(reqCtx).V(5).Info("Received admission request", "path", r.URL.Path, "remoteAddr", r.RemoteAddr)
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed, "only POST is supported")
+		return
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, ErrUnsupportedMediaType, fmt.Sprintf("unsupported Content-Type %q, expected application/json", r.Header.Get("Content-Type")))
+		return
+	}
+	if !s.acquireConcurrency() {
+		throttledTotal.Inc()
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+		writeError(w, http.StatusTooManyRequests, ErrTooManyRequests, "too many concurrent admission requests")
+		return
+	}
+	defer s.releaseConcurrency()
+
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	start := time.Now()
+	defer func() {
+		requestDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	var body []byte
+	if r.Body != nil {
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxRequestBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeError(w, http.StatusRequestEntityTooLarge, ErrBodyTooLarge, fmt.Sprintf("request body exceeds %d bytes", maxBytesErr.Limit))
+				return
+			}
+			writeError(w, http.StatusBadRequest, ErrReadBody, fmt.Sprintf("failed to read body: %v", err))
+			return
+		}
+		body = data
+	}
+	if len(body) == 0 {
+		writeError(w, http.StatusBadRequest, ErrEmptyBody, "empty body")
+		return
+	}
+
+	// Decode AdmissionReview request
+	ar, gvk, err := s.decodeAdmissionReview(body)
+	if err != nil {
+		klog.FromContext(reqCtx).Error(err, "Failed to decode AdmissionReview")
+		uid, gvk := peekAdmissionReview(body)
+		code := ErrDecode
+		if errors.Is(err, errNilRequest) {
+			code = ErrNilRequest
+		}
+		response := s.errorResponse(code, fmt.Sprintf("failed to decode body: %v", err))
+		response.UID = uid
+		s.writeReview(w, gvk, response)
+		return
+	}
+
+	if r.Header.Get(requestIDHeader) == "" && ar.Request.UID != "" {
+		reqCtx = withRequestID(r.Context(), w, string(ar.Request.UID))
+	}
+
+	// Process Pod
+	pod, err := decode(ar.Request)
+	if err != nil {
+		klog.FromContext(reqCtx).Error(err, "Failed to unmarshal pod")
+		response := s.errorResponse(ErrUnmarshal, fmt.Sprintf("failed to unmarshal pod: %v", err))
+		response.UID = ar.Request.UID
+		s.writeReview(w, gvk, response)
+		return
+	}
+	var old *corev1.Pod
+	if ar.Request.Operation == v1.Update && len(ar.Request.OldObject.Raw) > 0 {
+		oldRequest := *ar.Request
+		oldRequest.Object = ar.Request.OldObject
+		if old, err = decode(&oldRequest); err != nil {
+			klog.FromContext(reqCtx).Error(err, "Failed to unmarshal old pod")
+			response := s.errorResponse(ErrUnmarshal, fmt.Sprintf("failed to unmarshal old pod: %v", err))
+			response.UID = ar.Request.UID
+			s.writeReview(w, gvk, response)
+			return
+		}
+	}
+
+	// API calls made while evaluating the pod must finish well before the
+	// API server gives up on the webhook. A timeout surfaces as a lookup
+	// error and is handled like any other by --fail-open.
+	ctx, cancel := context.WithTimeout(reqCtx, s.apiTimeout)
+	defer cancel()
+	response := admit(ctx, ar, pod, old)
+	response.UID = ar.Request.UID
+	s.writeReview(w, gvk, response)
+}
+
+// writeReview sends the response, echoing the AdmissionReview version the API
+// server sent. The v1 and v1beta1 responses share the same wire format.
+func (s *Server) writeReview(w http.ResponseWriter, gvk *schema.GroupVersionKind, response *v1.AdmissionResponse) {
+	respBytes, err := json.Marshal(v1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+		},
+		Response: response,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to marshal response: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(respBytes)
+}
+
+// errorResponse returns the verdict configured by --on-error for a request that
+// could not be decoded. A denial carries code as the type of its status cause.
+func (s *Server) errorResponse(code ErrorCode, message string) *v1.AdmissionResponse {
+	recordDecision(&v1.AdmissionResponse{Allowed: s.onError == OnErrorAllow}, "", reasonDecodeError, "", false)
+	if s.onError == OnErrorAllow {
+		return &v1.AdmissionResponse{
+			Allowed:  true,
+			Warnings: []string{message},
+		}
+	}
+	response := denied(message)
+	response.Result.Reason = metav1.StatusReasonBadRequest
+	response.Result.Code = http.StatusBadRequest
+	response.Result.Details = &metav1.StatusDetails{Causes: []metav1.StatusCause{{Type: metav1.CauseType(code), Message: message}}}
+	return response
+}
+
+// peekAdmissionReview extracts whatever it can from a body that failed to
+// decode, so the error response can still be matched to the request.
+func peekAdmissionReview(body []byte) (types.UID, *schema.GroupVersionKind) {
+	var partial struct {
+		APIVersion string `json:"apiVersion"`
+		Request    struct {
+			UID types.UID `json:"uid"`
+		} `json:"request"`
+	}
+	gvk := v1.SchemeGroupVersion.WithKind("AdmissionReview")
+	if err := json.Unmarshal(body, &partial); err != nil {
+		return "", &gvk
+	}
+	if partial.APIVersion == v1beta1.SchemeGroupVersion.String() {
+		gvk = v1beta1.SchemeGroupVersion.WithKind("AdmissionReview")
+	}
+	return partial.Request.UID, &gvk
+}
+
+func (s *Server) admitValidate(ctx context.Context, ar *v1.AdmissionReview, pod, old *corev1.Pod) *v1.AdmissionResponse {
+	return s.admit(ctx, s.currentPolicy(), ar, pod, old)
+}
+
+func (s *Server) admit(ctx context.Context, p *policy.Policy, ar *v1.AdmissionReview, pod, old *corev1.Pod) *v1.AdmissionResponse {
+	start := time.Now()
+
+	p = p.ForNamespace(ar.Request.Namespace)
+	if old != nil && (ar.Request.SubResource == resizeSubresource || s.config.UpdateGrace) && !gpusIncreased(p, old, pod) {
+		response := &v1.AdmissionResponse{Allowed: true}
+		recordDecision(response, ar.Request.Namespace, policy.ReasonGPUsNotIncreased, resourceSet(p, pod), ar.Request.DryRun != nil && *ar.Request.DryRun)
+		return response
+	}
+	var decision policy.Decision
+	message, deprecated := p.DeprecatedGPUMessage(pod)
+	switch {
+	case deprecated && ar.Request.Operation == v1.Create && !p.IsSkippedNamespace(ar.Request.Namespace):
+		decision = policy.Decision{Reason: policy.ReasonDeprecatedGPU, Message: message}
+	case s.rego != nil:
+		decision = s.evaluateRego(ctx, ar)
+	default:
+		decision = s.evaluator.Evaluate(ctx, p, pod, ar.Request.Namespace)
+	}
+	// Dry-run requests still get the real verdict, but must not trigger any
+	// external side effects.
+	dryRun := ar.Request.DryRun != nil && *ar.Request.DryRun
+	if decision.Exemption != "" {
+		exemptionsTotal.WithLabelValues(decision.Reason).Inc()
+		klog.FromContext(ctx).Info("Exempting pod from GPU policy",
+			"namespace", ar.Request.Namespace,
+			"pod", policy.PodDisplayName(pod),
+			"user", ar.Request.UserInfo.Username,
+			"reason", decision.Reason,
+			"rule", decision.Exemption,
+			"dryRun", dryRun,
+		)
+	}
+	if _, requestsGPU := p.FindGPUResource(pod); decision.Allowed && requestsGPU && s.delegate != nil && !p.IsSkippedNamespace(ar.Request.Namespace) {
+		decision = s.evaluateDelegate(ctx, ar, decision)
+	}
+	if decision.Allowed && decision.Exemption == "" && s.userBudget != nil && ar.Request.Operation == v1.Create && ar.Request.Kind.Group == "" &&
+		!p.IsSkippedNamespace(ar.Request.Namespace) && countsAgainstUserBudget(ar.Request.UserInfo.Username) {
+		if gpus := p.PodGPURequests(pod); gpus > 0 {
+			decision = s.chargeUserBudget(ctx, ar, gpus, decision, dryRun)
+		}
+	}
+	// Denials made here rather than by the evaluator still honor warn mode.
+	if !decision.Allowed && p.Mode == policy.ModeWarn {
+		decision = policy.Decision{Allowed: true, Reason: decision.Reason, Warnings: append(decision.Warnings, decision.Message)}
+	}
+	response, reason, resources := admissionResponse(decision), decision.Reason, resourceSet(p, pod)
+	recordDecision(response, ar.Request.Namespace, reason, resources, dryRun)
+	if s.recent != nil && decisionLabel(response) != decisionAllowed {
+		decision := recentDecision{
+			Timestamp:    start,
+			UID:          ar.Request.UID,
+			Namespace:    ar.Request.Namespace,
+			Pod:          pod.Name,
+			GenerateName: pod.GenerateName,
+			User:         ar.Request.UserInfo.Username,
+			Policy:       p.DisplayName(),
+			Decision:     decisionLabel(response),
+			Reason:       reason,
+			DryRun:       dryRun,
+		}
+		if response.Result != nil {
+			decision.Message = response.Result.Message
+		} else if len(response.Warnings) > 0 {
+			decision.Message = response.Warnings[0]
+		}
+		s.recent.Add(decision)
+	}
+	if !response.Allowed && !dryRun {
+		recordDeniedGPUs(p, pod, ar.Request.Namespace)
+		err := s.audit.Log(auditEntry{
+			Timestamp: start,
+			UID:       ar.Request.UID,
+			User:      ar.Request.UserInfo.Username,
+			Groups:    ar.Request.UserInfo.Groups,
+			Namespace: ar.Request.Namespace,
+			Pod:       policy.PodDisplayName(pod),
+			Policy:    p.DisplayName(),
+			Reason:    reason,
+			Message:   response.Result.Message,
+		})
+		if err != nil {
+			klog.FromContext(ctx).Error(err, "Failed to write audit entry")
+		}
+		// Workload and custom resource denials, anything but core pods, are
+		// returned to kubectl directly and there is no pod or controller
+		// revision to attach an event to yet.
+		if s.events != nil && ar.Request.Kind.Group == "" {
+			s.events.Denied(pod, ar.Request.Namespace, response.Result.Message)
+		}
+	}
+
+	// Routine allows are only logged at -v=4, denials and warnings always.
+	logger := klog.FromContext(ctx)
+	if decisionLabel(response) == decisionAllowed {
+		logger = logger.V(4)
+	}
+	logger.Info("Admission decision",
+		"uid", ar.Request.UID,
+		"namespace", ar.Request.Namespace,
+		"pod", policy.PodDisplayName(pod),
+		"policy", p.DisplayName(),
+		"decision", decisionLabel(response),
+		"reason", reason,
+		"resources", resources,
+		"dryRun", dryRun,
+		"duration", time.Since(start),
+	)
+	return response
+}
+
+var errNilRequest = errors.New("AdmissionReview has no request")
+
+// decodeAdmissionReview decodes a v1 or v1beta1 AdmissionReview. Legacy
+// v1beta1 requests are converted to v1 so the rest of the handler only deals
+// with a single version.
+func (s *Server) decodeAdmissionReview(body []byte) (*v1.AdmissionReview, *schema.GroupVersionKind, error) {
+	obj, gvk, err := s.decoder.UniversalDeserializer().Decode(body, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch review := obj.(type) {
+	case *v1.AdmissionReview:
+		if review.Request == nil {
+			return nil, nil, errNilRequest
+		}
+		return review, gvk, nil
+	case *v1beta1.AdmissionReview:
+		if review.Request == nil {
+			return nil, nil, errNilRequest
+		}
+		data, err := json.Marshal(review.Request)
+		if err != nil {
+			return nil, nil, err
+		}
+		ar := &v1.AdmissionReview{Request: &v1.AdmissionRequest{}}
+		if err := json.Unmarshal(data, ar.Request); err != nil {
+			return nil, nil, err
+		}
+		return ar, gvk, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported object %s", gvk)
+	}
+}
+
+// admissionResponse converts a policy decision to an admission response.
+func admissionResponse(decision policy.Decision) *v1.AdmissionResponse {
+	if !decision.Allowed {
+		response := denied(decision.Message)
+		if len(decision.Causes) > 0 {
+			response.Result.Details = &metav1.StatusDetails{Causes: decision.Causes}
+		}
+		return response
+	}
+	return &v1.AdmissionResponse{
+		Allowed:  true,
+		Warnings: decision.Warnings,
+	}
+}
+
+func denied(message string) *v1.AdmissionResponse {
+	return &v1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: message,
+			Reason:  metav1.StatusReasonForbidden,
+			Code:    http.StatusForbidden,
+		},
+	}
+}
+
+func (s *Server) initClientset() error {
+	config, err := clientcmd.BuildConfigFromFlags("", s.config.Kubeconfig)
+	if err != nil {
+		return fmt.Errorf("build kubeconfig: %w", err)
+	}
+	// Admission lookups are limited by apiLimiter, which fails fast rather
+	// than blocking like client-go's limiter, so only one of them applies.
+	config.RateLimiter = flowcontrol.NewFakeAlwaysRateLimiter()
+	s.apiLimiter = rate.NewLimiter(rate.Limit(s.config.APIQPS), s.config.APIBurst)
+	s.clientset, err = kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("build kubernetes clientset: %w", err)
+	}
+	klog.Infof("Successfully initialized kubernetes clientset")
+	return nil
+}
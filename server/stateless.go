@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// errNoClientset is returned by cluster lookups when the webhook runs without
+// a clientset. The evaluator treats it like any other failed lookup, so
+// --fail-open decides the outcome.
+var errNoClientset = errors.New("webhook is running without a kubernetes clientset")
+
+// noNamespaces is the namespace getter of a webhook without a clientset.
+type noNamespaces struct{}
+
+func (noNamespaces) Get(ctx context.Context, name string) (*corev1.Namespace, error) {
+	return nil, errNoClientset
+}
+
+// clientsetFeatures lists the enabled features that cannot work without a
+// clientset.
+func clientsetFeatures(config Config, p *policy.Policy) []string {
+	var features []string
+	if p.HasNamespaceQuota() {
+		features = append(features, "the namespace GPU quota")
+	}
+	if len(p.ExemptPriorityClasses) > 0 || p.MinExemptPriority != nil {
+		features = append(features, "priority exemptions")
+	}
+	if config.CheckResourceQuota {
+		features = append(features, "--check-resource-quota")
+	}
+	if config.ApplyLimitRangeDefaults {
+		features = append(features, "--apply-limit-range-defaults")
+	}
+	if config.CheckNodeCapacity {
+		features = append(features, "--check-node-capacity")
+	}
+	if config.EmitEvents {
+		features = append(features, "--emit-events")
+	}
+	if config.PolicyConfigMap != "" {
+		features = append(features, "--policy-configmap")
+	}
+	return features
+}
+
+// callAPI fails fast when there is no clientset to call the API server with,
+// and otherwise runs fn with the --api-qps rate limit and retries.
+func (s *Server) callAPI(ctx context.Context, call string, fn func() error) error {
+	if s.clientset == nil {
+		return errNoClientset
+	}
+	return callAPI(ctx, s.apiLimiter, call, fn)
+}
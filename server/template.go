@@ -0,0 +1,122 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	v1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// templatePath locates the pod template, or pod spec, embedded in a custom
+// resource. The expression is validated once but parsed again for every
+// request, as a *jsonpath.JSONPath keeps range state while it evaluates and
+// cannot be shared between concurrent requests.
+type templatePath struct {
+	name       string
+	expression string
+}
+
+// find evaluates the path against object.
+func (p *templatePath) find(object any) ([][]reflect.Value, error) {
+	path := jsonpath.New(p.name)
+	if err := path.Parse(p.expression); err != nil {
+		return nil, err
+	}
+	return path.FindResults(object)
+}
+
+// parseTemplatePaths compiles the --template-paths entries, keyed by
+// group/version/resource, e.g. argoproj.io/v1alpha1/workflows.
+func parseTemplatePaths(paths map[string]string) (map[schema.GroupVersionResource]*templatePath, error) {
+	compiled := make(map[schema.GroupVersionResource]*templatePath, len(paths))
+	for key, expression := range paths {
+		parts := strings.Split(key, "/")
+		if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid template resource %q, expected group/version/resource", key)
+		}
+		gvr := schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]}
+		if !strings.HasPrefix(expression, "{") {
+			expression = "{" + expression + "}"
+		}
+		if err := jsonpath.New(key).Parse(expression); err != nil {
+			return nil, fmt.Errorf("invalid template path %q for %s: %w", expression, key, err)
+		}
+		compiled[gvr] = &templatePath{name: key, expression: expression}
+	}
+	return compiled, nil
+}
+
+// validateTemplate validates the pod template embedded in custom resources,
+// such as workflow engines' CRDs, at the JSONPath configured for the
+// resource with --template-paths.
+func (s *Server) validateTemplate(w http.ResponseWriter, r *http.Request) {
+	s.serveAdmission(w, r, s.decodeTemplate, s.admitTemplate)
+}
+
+// decodeTemplate returns a pod built from the template the resource's path
+// selects, owned by the custom resource so that owner kind limits apply. The
+// path must select exactly one object, either a pod template with a spec or
+// a pod spec.
+func (s *Server) decodeTemplate(request *v1.AdmissionRequest) (*corev1.Pod, error) {
+	gvr := schema.GroupVersionResource{Group: request.Resource.Group, Version: request.Resource.Version, Resource: request.Resource.Resource}
+	path, ok := s.templatePaths[gvr]
+	if !ok {
+		return nil, fmt.Errorf("no template path is configured for %s", gvr)
+	}
+
+	var object map[string]any
+	if err := json.Unmarshal(request.Object.Raw, &object); err != nil {
+		return nil, err
+	}
+	results, err := path.find(object)
+	if err != nil {
+		return nil, fmt.Errorf("template path %s: %w", path.expression, err)
+	}
+	var matches []any
+	for _, result := range results {
+		for _, value := range result {
+			matches = append(matches, value.Interface())
+		}
+	}
+	if len(matches) != 1 {
+		return nil, fmt.Errorf("template path %s selects %d objects, expected one", path.expression, len(matches))
+	}
+	data, err := json.Marshal(matches[0])
+	if err != nil {
+		return nil, err
+	}
+	var template corev1.PodTemplateSpec
+	if err := json.Unmarshal(data, &template); err != nil {
+		return nil, fmt.Errorf("template path %s: %w", path.expression, err)
+	}
+	if len(template.Spec.Containers) == 0 {
+		// Not a template, try a bare pod spec.
+		template = corev1.PodTemplateSpec{}
+		if err := json.Unmarshal(data, &template.Spec); err != nil {
+			return nil, fmt.Errorf("template path %s: %w", path.expression, err)
+		}
+	}
+
+	var meta metav1.PartialObjectMetadata
+	if err := json.Unmarshal(request.Object.Raw, &meta); err != nil {
+		return nil, err
+	}
+	controller := true
+	pod := &corev1.Pod{ObjectMeta: template.ObjectMeta, Spec: template.Spec}
+	pod.Name = meta.Name
+	pod.Namespace = meta.Namespace
+	pod.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: meta.APIVersion,
+		Kind:       meta.Kind,
+		Name:       meta.Name,
+		Controller: &controller,
+	}}
+	return pod, nil
+}
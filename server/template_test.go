@@ -0,0 +1,180 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	v1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	server := newTestServer(policy.Policy{
+		GPUPrefixes:              []string{"nvidia.com"},
+		MaxGPUsPerPod:            1,
+		MaxGPUsPerPodByOwnerKind: map[string]int64{"TrainingRun": 2},
+		MaxMIGDevicesPerPod:      -1,
+		MaxGPUsPerNamespace:      -1,
+	}, testNamespace("default", nil))
+	server.audit = &auditLogger{w: io.Discard}
+	paths, err := parseTemplatePaths(map[string]string{
+		"example.com/v1/trainingruns": ".spec.worker.template",
+		"example.com/v1/sweeps":       "{.spec.trials[*].podSpec}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.templatePaths = paths
+
+	tests := []struct {
+		name        string
+		resource    string
+		object      string
+		wantAllowed bool
+		wantMessage string
+	}{
+		{
+			name:        "pod template within the owner kind limit",
+			resource:    "trainingruns",
+			object:      `{"apiVersion":"example.com/v1","kind":"TrainingRun","metadata":{"name":"run"},"spec":{"worker":{"template":{"spec":{"containers":[{"name":"app","resources":{"limits":{"nvidia.com/gpu":"2"}}}]}}}}}`,
+			wantAllowed: true,
+		},
+		{
+			name:     "pod template over the owner kind limit",
+			resource: "trainingruns",
+			object:   `{"apiVersion":"example.com/v1","kind":"TrainingRun","metadata":{"name":"run"},"spec":{"worker":{"template":{"spec":{"containers":[{"name":"app","resources":{"limits":{"nvidia.com/gpu":"3"}}}]}}}}}`,
+		},
+		{
+			name:        "pod spec",
+			resource:    "sweeps",
+			object:      `{"apiVersion":"example.com/v1","kind":"Sweep","metadata":{"name":"sweep"},"spec":{"trials":[{"podSpec":{"containers":[{"name":"app","resources":{"limits":{"nvidia.com/gpu":"1"}}}]}}]}}`,
+			wantAllowed: true,
+		},
+		{
+			name:        "several templates",
+			resource:    "sweeps",
+			object:      `{"apiVersion":"example.com/v1","kind":"Sweep","metadata":{"name":"sweep"},"spec":{"trials":[{"podSpec":{"containers":[]}},{"podSpec":{"containers":[]}}]}}`,
+			wantMessage: "failed to unmarshal pod: template path {.spec.trials[*].podSpec} selects 2 objects, expected one",
+		},
+		{
+			name:        "unconfigured resource",
+			resource:    "experiments",
+			object:      `{"apiVersion":"example.com/v1","kind":"Experiment","metadata":{"name":"exp"}}`,
+			wantMessage: "failed to unmarshal pod: no template path is configured for example.com/v1, Resource=experiments",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(v1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &v1.AdmissionRequest{
+					UID:       "abc",
+					Namespace: "default",
+					Kind:      metav1.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "TrainingRun"},
+					Resource:  metav1.GroupVersionResource{Group: "example.com", Version: "v1", Resource: tt.resource},
+					Object:    runtime.RawExtension{Raw: []byte(tt.object)},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			server.validateTemplate(recorder, admissionRequest("/validate-templates", strings.NewReader(string(body))))
+			var review v1.AdmissionReview
+			if err := json.Unmarshal(recorder.Body.Bytes(), &review); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if review.Response.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v: %+v", review.Response.Allowed, tt.wantAllowed, review.Response.Result)
+			}
+			if tt.wantMessage != "" && review.Response.Result.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", review.Response.Result.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestDecodeTemplateConcurrent(t *testing.T) {
+	server := newTestServer(policy.Policy{GPUPrefixes: []string{"nvidia.com"}})
+	paths, err := parseTemplatePaths(map[string]string{
+		"example.com/v1/jobsets": "{range .spec.workers[0:1]}{.template}{end}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.templatePaths = paths
+
+	for i := range 8 {
+		t.Run(fmt.Sprintf("request %d", i), func(t *testing.T) {
+			t.Parallel()
+			name := fmt.Sprintf("app-%d", i)
+			request := &v1.AdmissionRequest{
+				Resource: metav1.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "jobsets"},
+				Object: runtime.RawExtension{Raw: []byte(`{"apiVersion":"example.com/v1","kind":"JobSet","metadata":{"name":"set"},"spec":{"workers":[` +
+					`{"template":{"spec":{"containers":[{"name":"` + name + `"}]}}},` +
+					`{"template":{"spec":{"containers":[{"name":"other"}]}}}]}}`)},
+			}
+			for range 50 {
+				pod, err := server.decodeTemplate(request)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := pod.Spec.Containers[0].Name; got != name {
+					t.Fatalf("container = %q, want %q", got, name)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateTemplateAtNamespaceQuota(t *testing.T) {
+	running := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "run-worker-0", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 2))}},
+	}
+	server := newTestServer(policy.Policy{
+		GPUPrefixes:         []string{"nvidia.com"},
+		MaxGPUsPerPod:       2,
+		MaxMIGDevicesPerPod: -1,
+		MaxGPUsPerNamespace: 2,
+	}, testNamespace("default", nil), running)
+	server.audit = &auditLogger{w: io.Discard}
+	paths, err := parseTemplatePaths(map[string]string{"example.com/v1/trainingruns": ".spec.worker.template"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.templatePaths = paths
+
+	body, err := json.Marshal(v1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &v1.AdmissionRequest{
+			UID:       "abc",
+			Namespace: "default",
+			Operation: v1.Update,
+			Kind:      metav1.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "TrainingRun"},
+			Resource:  metav1.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "trainingruns"},
+			Object: runtime.RawExtension{Raw: []byte(`{"apiVersion":"example.com/v1","kind":"TrainingRun","metadata":{"name":"run","namespace":"default"},` +
+				`"spec":{"worker":{"template":{"spec":{"containers":[{"name":"app","resources":{"limits":{"nvidia.com/gpu":"2"}}}]}}}}}`)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	server.validateTemplate(recorder, admissionRequest("/validate-templates", strings.NewReader(string(body))))
+	var review v1.AdmissionReview
+	if err := json.Unmarshal(recorder.Body.Bytes(), &review); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !review.Response.Allowed {
+		t.Errorf("update in a namespace at quota denied: %+v", review.Response.Result)
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	v1 "k8s.io/api/admission/v1"
+	"k8s.io/klog/v2"
+)
+
+// DefaultUserBudgetWindow is the window --max-gpus-per-user applies to.
+const DefaultUserBudgetWindow = time.Hour
+
+// controllerUserPrefix identifies the service accounts of the built-in
+// controllers, which create pods on behalf of other users.
+const controllerUserPrefix = "system:serviceaccount:kube-system:"
+
+// userBudgetStore tracks the GPUs granted to each user within the window of
+// --max-gpus-per-user.
+type userBudgetStore interface {
+	// Reserve grants gpus to user if that keeps the user within the budget.
+	// It returns the GPUs already granted to the user within the window. A
+	// dry run only checks the budget.
+	Reserve(ctx context.Context, user string, gpus int64, dryRun bool) (int64, bool, error)
+	// Usage returns the GPUs granted to each user within the window, sorted
+	// by user.
+	Usage(ctx context.Context) ([]userUsage, error)
+}
+
+// userGrant is a number of GPUs granted to a user at a point in time.
+type userGrant struct {
+	at   time.Time
+	gpus int64
+}
+
+// userBudget is the in-memory userBudgetStore used without Redis. Every
+// replica of the webhook enforces the budget on the requests it happens to
+// serve.
+type userBudget struct {
+	mu     sync.Mutex
+	max    int64
+	window time.Duration
+	now    func() time.Time
+	grants map[string][]userGrant
+	// swept is when the grants of every user were last pruned, so that
+	// users who stop creating pods do not keep their entry forever.
+	swept time.Time
+}
+
+func newUserBudget(max int64, window time.Duration) *userBudget {
+	return &userBudget{max: max, window: window, now: time.Now, grants: make(map[string][]userGrant)}
+}
+
+func (b *userBudget) Reserve(ctx context.Context, user string, gpus int64, dryRun bool) (int64, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if now.Sub(b.swept) >= b.window {
+		for other := range b.grants {
+			b.prune(other, now)
+		}
+		b.swept = now
+	}
+	used := b.prune(user, now)
+	if used+gpus > b.max {
+		return used, false, nil
+	}
+	if dryRun {
+		return used, true, nil
+	}
+	b.grants[user] = append(b.grants[user], userGrant{at: now, gpus: gpus})
+	return used, true, nil
+}
+
+// prune drops the user's grants that left the window and returns the sum of
+// the rest.
+func (b *userBudget) prune(user string, now time.Time) int64 {
+	grants := b.grants[user]
+	start := sort.Search(len(grants), func(i int) bool { return now.Sub(grants[i].at) < b.window })
+	grants = grants[start:]
+	if len(grants) == 0 {
+		delete(b.grants, user)
+		return 0
+	}
+	b.grants[user] = grants
+	var used int64
+	for _, grant := range grants {
+		used += grant.gpus
+	}
+	return used
+}
+
+// userUsage is one entry served by /debug/users.
+type userUsage struct {
+	User string `json:"user"`
+	GPUs int64  `json:"gpus"`
+}
+
+func (b *userBudget) Usage(ctx context.Context) ([]userUsage, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	usage := make([]userUsage, 0, len(b.grants))
+	for user := range b.grants {
+		if used := b.prune(user, now); used > 0 {
+			usage = append(usage, userUsage{User: user, GPUs: used})
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].User < usage[j].User })
+	return usage, nil
+}
+
+// countsAgainstUserBudget reports whether pods created by user are charged to
+// them. Pods created by the built-in controllers, such as the ReplicaSet and
+// Job controllers, are not.
+func countsAgainstUserBudget(user string) bool {
+	return user != "" && !strings.HasPrefix(user, controllerUserPrefix)
+}
+
+// chargeUserBudget charges the GPUs of an allowed pod to the user creating it,
+// denying the pod when the user's budget is exhausted.
+func (s *Server) chargeUserBudget(ctx context.Context, ar *v1.AdmissionReview, gpus int64, decision policy.Decision, dryRun bool) policy.Decision {
+	user := ar.Request.UserInfo.Username
+	used, ok, err := s.userBudget.Reserve(ctx, user, gpus, dryRun)
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Failed to check user GPU budget", "user", user)
+		if s.config.FailOpen {
+			decision.Reason = policy.ReasonUserBudgetLookupFailed
+			return decision
+		}
+		return policy.Decision{Reason: policy.ReasonUserBudgetLookupFailed, Message: fmt.Sprintf("unable to verify GPU budget for user %s: %v", user, err), Warnings: decision.Warnings}
+	}
+	if ok {
+		return decision
+	}
+	klog.FromContext(ctx).V(2).Info("User GPU budget exhausted", "user", user, "used", used, "requested", gpus, "budget", s.config.MaxGPUsPerUser)
+	return policy.Decision{
+		Reason: policy.ReasonUserBudgetExceeded,
+		Message: fmt.Sprintf("user %s was granted %d GPUs in the last %s, another %d would exceed the budget of %d GPUs per user",
+			user, used, s.config.UserBudgetWindow, gpus, s.config.MaxGPUsPerUser),
+		Warnings: decision.Warnings,
+	}
+}
+
+// debugUsers serves the GPUs granted to each user within the budget window.
+func (s *Server) debugUsers(w http.ResponseWriter, r *http.Request) {
+	usage := []userUsage{}
+	if s.userBudget != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), s.apiTimeout)
+		defer cancel()
+		var err error
+		if usage, err = s.userBudget.Usage(ctx); err != nil {
+			writeError(w, http.StatusServiceUnavailable, ErrUserBudget, fmt.Sprintf("failed to read user GPU budgets: %v", err))
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	v1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestUserBudget(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	budget := newUserBudget(4, time.Hour)
+	budget.now = func() time.Time { return now }
+
+	steps := []struct {
+		advance  time.Duration
+		user     string
+		gpus     int64
+		dryRun   bool
+		wantUsed int64
+		wantOK   bool
+	}{
+		{user: "alice", gpus: 3, wantUsed: 0, wantOK: true},
+		{advance: 10 * time.Minute, user: "alice", gpus: 2, wantUsed: 3, wantOK: false},
+		{user: "bob", gpus: 4, wantUsed: 0, wantOK: true},
+		{user: "alice", gpus: 1, dryRun: true, wantUsed: 3, wantOK: true},
+		{user: "alice", gpus: 1, wantUsed: 3, wantOK: true},
+		{user: "alice", gpus: 1, wantUsed: 4, wantOK: false},
+		// The first grant leaves the window an hour after it was made.
+		{advance: 50 * time.Minute, user: "alice", gpus: 3, wantUsed: 1, wantOK: true},
+	}
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		used, ok, err := budget.Reserve(context.Background(), step.user, step.gpus, step.dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if used != step.wantUsed || ok != step.wantOK {
+			t.Errorf("step %d: Reserve(%s, %d) = %d, %v, want %d, %v", i, step.user, step.gpus, used, ok, step.wantUsed, step.wantOK)
+		}
+	}
+
+	want := []userUsage{{User: "alice", GPUs: 4}, {User: "bob", GPUs: 4}}
+	if got, _ := budget.Usage(context.Background()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Usage() = %v, want %v", got, want)
+	}
+	now = now.Add(time.Hour)
+	if got, _ := budget.Usage(context.Background()); len(got) != 0 {
+		t.Errorf("Usage() after the window = %v, want none", got)
+	}
+}
+
+func TestUserBudgetForgetsIdleUsers(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	budget := newUserBudget(4, time.Hour)
+	budget.now = func() time.Time { return now }
+
+	for _, user := range []string{"alice", "bob", "carol"} {
+		if _, _, err := budget.Reserve(context.Background(), user, 1, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(budget.grants) != 3 {
+		t.Fatalf("tracking %d users, want 3", len(budget.grants))
+	}
+
+	// Only dave creates pods once the others' grants leave the window.
+	now = now.Add(90 * time.Minute)
+	if _, _, err := budget.Reserve(context.Background(), "dave", 1, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := budget.grants["dave"]; !ok || len(budget.grants) != 1 {
+		t.Errorf("tracking %v, want only dave", slices.Sorted(maps.Keys(budget.grants)))
+	}
+}
+
+func TestValidatePodUserBudget(t *testing.T) {
+	server := newTestServer(policy.Policy{GPUPrefixes: []string{"nvidia.com"}})
+	server.audit = &auditLogger{w: io.Discard}
+	server.evaluator = stubEvaluator(policy.Decision{Allowed: true, Reason: policy.ReasonWithinLimit})
+	server.config.MaxGPUsPerUser, server.config.UserBudgetWindow = 4, time.Hour
+	server.userBudget = newUserBudget(4, time.Hour)
+
+	raw, err := json.Marshal(corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 3))}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		user        string
+		wantAllowed bool
+	}{
+		{name: "first pod", user: "alice", wantAllowed: true},
+		{name: "over budget", user: "alice", wantAllowed: false},
+		{name: "other user", user: "bob", wantAllowed: true},
+		{name: "controller", user: "system:serviceaccount:kube-system:replicaset-controller", wantAllowed: true},
+		{name: "controller again", user: "system:serviceaccount:kube-system:replicaset-controller", wantAllowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(v1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &v1.AdmissionRequest{
+					UID:       "abc",
+					Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+					Namespace: "team",
+					Operation: v1.Create,
+					UserInfo:  authenticationv1.UserInfo{Username: tt.user},
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder := httptest.NewRecorder()
+			server.validatePod(recorder, admissionRequest("/validate", strings.NewReader(string(body))))
+			var review v1.AdmissionReview
+			if err := json.Unmarshal(recorder.Body.Bytes(), &review); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if review.Response.Allowed != tt.wantAllowed {
+				t.Fatalf("Allowed = %v, want %v", review.Response.Allowed, tt.wantAllowed)
+			}
+			if !tt.wantAllowed {
+				want := "user alice was granted 3 GPUs in the last 1h0m0s, another 3 would exceed the budget of 4 GPUs per user"
+				if review.Response.Result.Message != want {
+					t.Errorf("message = %q, want %q", review.Response.Result.Message, want)
+				}
+			}
+		})
+	}
+
+	recorder := httptest.NewRecorder()
+	server.debugUsers(recorder, httptest.NewRequest(http.MethodGet, "/debug/users", nil))
+	var usage []userUsage
+	if err := json.Unmarshal(recorder.Body.Bytes(), &usage); err != nil {
+		t.Fatalf("failed to decode usage: %v", err)
+	}
+	if want := []userUsage{{User: "alice", GPUs: 3}, {User: "bob", GPUs: 3}}; !reflect.DeepEqual(usage, want) {
+		t.Errorf("usage = %v, want %v", usage, want)
+	}
+}
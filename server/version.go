@@ -0,0 +1,28 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// BuildInfo identifies the webhook binary.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	BuildDate string `json:"buildDate"`
+}
+
+// versionReport is served on /version.
+type versionReport struct {
+	BuildInfo
+	// PolicyHash identifies the policy currently enforced, see policy.Hash.
+	PolicyHash string `json:"policyHash"`
+}
+
+func (s *Server) version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versionReport{
+		BuildInfo:  s.config.BuildInfo,
+		PolicyHash: s.currentPolicy().Hash(),
+	})
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	v1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// validateWorkload validates the pod template of apps/v1 Deployments and
+// StatefulSets, and batch/v1 Jobs and CronJobs, against the default policy, so
+// that users see a denial on kubectl apply instead of as events on a
+// ReplicaSet or Job they never created.
+func (s *Server) validateWorkload(w http.ResponseWriter, r *http.Request) {
+	s.serveAdmission(w, r, s.decodeWorkload, s.admitTemplate)
+}
+
+// admitTemplate evaluates a pod built from a template against the default
+// policy, leaving the usage-based checks to the pods created from it.
+func (s *Server) admitTemplate(ctx context.Context, ar *v1.AdmissionReview, pod, old *corev1.Pod) *v1.AdmissionResponse {
+	return s.admitValidate(policy.ForTemplate(ctx), ar, pod, old)
+}
+
+// decodeWorkload returns a pod built from the workload's template, owned the
+// way the controller will own the real pods so that owner kind limits apply.
+func (s *Server) decodeWorkload(request *v1.AdmissionRequest) (*corev1.Pod, error) {
+	obj, _, err := s.decoder.UniversalDeserializer().Decode(request.Object.Raw, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta metav1.ObjectMeta
+	var template corev1.PodTemplateSpec
+	var ownerKind string
+	switch workload := obj.(type) {
+	case *appsv1.Deployment:
+		meta, template = workload.ObjectMeta, workload.Spec.Template
+		// Deployment pods are owned by the ReplicaSet of their revision.
+		ownerKind = "ReplicaSet"
+	case *appsv1.StatefulSet:
+		meta, template = workload.ObjectMeta, workload.Spec.Template
+		ownerKind = "StatefulSet"
+	case *batchv1.Job:
+		meta, template = workload.ObjectMeta, workload.Spec.Template
+		ownerKind = "Job"
+	case *batchv1.CronJob:
+		// CronJob pods are owned by the Job of each run.
+		meta, template = workload.ObjectMeta, workload.Spec.JobTemplate.Spec.Template
+		ownerKind = "Job"
+	default:
+		return nil, fmt.Errorf("unsupported workload %T, expected an apps/v1 Deployment or StatefulSet or a batch/v1 Job or CronJob", obj)
+	}
+
+	controller := true
+	pod := &corev1.Pod{
+		ObjectMeta: *template.ObjectMeta.DeepCopy(),
+		Spec:       *template.Spec.DeepCopy(),
+	}
+	pod.Name = meta.Name
+	pod.Namespace = meta.Namespace
+	apiVersion := appsv1.SchemeGroupVersion.String()
+	if ownerKind == "Job" {
+		apiVersion = batchv1.SchemeGroupVersion.String()
+	}
+	pod.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: apiVersion,
+		Kind:       ownerKind,
+		Name:       meta.Name,
+		Controller: &controller,
+	}}
+	return pod, nil
+}
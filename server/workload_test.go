@@ -0,0 +1,188 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mayooot/gpu-policy-webhook/policy"
+	"k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidateWorkload(t *testing.T) {
+	server := newTestServer(policy.Policy{
+		GPUPrefixes:              []string{"nvidia.com"},
+		MaxGPUsPerPod:            1,
+		MaxGPUsPerPodByOwnerKind: map[string]int64{"ReplicaSet": 2, "Job": 4},
+		MaxMIGDevicesPerPod:      -1,
+		MaxGPUsPerNamespace:      -1,
+	}, testNamespace("default", nil))
+	server.audit = &auditLogger{w: io.Discard}
+
+	template := func(count int64) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", count))}}}
+	}
+	tests := []struct {
+		name        string
+		object      runtime.Object
+		wantAllowed bool
+	}{
+		{
+			name: "deployment within the ReplicaSet limit",
+			object: &appsv1.Deployment{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+				ObjectMeta: metav1.ObjectMeta{Name: "train"},
+				Spec:       appsv1.DeploymentSpec{Template: template(2)},
+			},
+			wantAllowed: true,
+		},
+		{
+			name: "deployment over the ReplicaSet limit",
+			object: &appsv1.Deployment{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+				ObjectMeta: metav1.ObjectMeta{Name: "train"},
+				Spec:       appsv1.DeploymentSpec{Template: template(3)},
+			},
+		},
+		{
+			name: "statefulset over the default limit",
+			object: &appsv1.StatefulSet{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+				ObjectMeta: metav1.ObjectMeta{Name: "serve"},
+				Spec:       appsv1.StatefulSetSpec{Template: template(2)},
+			},
+		},
+		{
+			name: "job within the Job limit",
+			object: &batchv1.Job{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+				ObjectMeta: metav1.ObjectMeta{Name: "finetune"},
+				Spec:       batchv1.JobSpec{Template: template(4)},
+			},
+			wantAllowed: true,
+		},
+		{
+			name: "cronjob over the Job limit",
+			object: &batchv1.CronJob{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "CronJob"},
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly"},
+				Spec: batchv1.CronJobSpec{
+					Schedule:    "0 2 * * *",
+					JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: template(5)}},
+				},
+			},
+		},
+		{
+			name: "unsupported kind",
+			object: &appsv1.DaemonSet{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "DaemonSet"},
+				ObjectMeta: metav1.ObjectMeta{Name: "agent"},
+				Spec:       appsv1.DaemonSetSpec{Template: template(0)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(tt.object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := json.Marshal(v1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &v1.AdmissionRequest{
+					UID:       "abc",
+					Kind:      metav1.GroupVersionKind(tt.object.GetObjectKind().GroupVersionKind()),
+					Namespace: "default",
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			server.validateWorkload(recorder, admissionRequest("/validate-workloads", strings.NewReader(string(body))))
+			var review v1.AdmissionReview
+			if err := json.Unmarshal(recorder.Body.Bytes(), &review); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if review.Response.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v (%+v)", review.Response.Allowed, tt.wantAllowed, review.Response.Result)
+			}
+		})
+	}
+}
+
+func TestValidateWorkloadAtNamespaceQuota(t *testing.T) {
+	controller := true
+	running := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       "default",
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "train-7f9c", Controller: &controller}},
+			},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", 2))}},
+		}
+	}
+	server := newTestServer(policy.Policy{
+		GPUPrefixes:         []string{"nvidia.com"},
+		MaxGPUsPerPod:       2,
+		MaxMIGDevicesPerPod: -1,
+		MaxGPUsPerNamespace: 4,
+	}, testNamespace("default", nil), running("train-7f9c-a"), running("train-7f9c-b"))
+	server.audit = &auditLogger{w: io.Discard}
+
+	tests := []struct {
+		name        string
+		gpus        int64
+		wantAllowed bool
+	}{
+		{name: "unchanged apply", gpus: 2, wantAllowed: true},
+		{name: "over the per-pod limit", gpus: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(&appsv1.Deployment{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+				ObjectMeta: metav1.ObjectMeta{Name: "train", Namespace: "default"},
+				Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					Containers: []corev1.Container{container("app", gpus("nvidia.com/gpu", tt.gpus))},
+				}}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := json.Marshal(v1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &v1.AdmissionRequest{
+					UID:       "abc",
+					Kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+					Namespace: "default",
+					Operation: v1.Update,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			recorder := httptest.NewRecorder()
+			server.validateWorkload(recorder, admissionRequest("/validate-workloads", strings.NewReader(string(body))))
+			var review v1.AdmissionReview
+			if err := json.Unmarshal(recorder.Body.Bytes(), &review); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if review.Response.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v (%+v)", review.Response.Allowed, tt.wantAllowed, review.Response.Result)
+			}
+		})
+	}
+}